@@ -0,0 +1,83 @@
+package goreq
+
+import (
+	"context"
+	"sync"
+)
+
+// Future is the pending result of a request started with DoAsync.
+type Future struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	res       *Response
+	err       error
+	callbacks []func(*Response, error)
+}
+
+// DoAsync starts sending r in a new goroutine and returns right away.
+func (r Request) DoAsync() *Future {
+	return startFuture(r, Request.Do)
+}
+
+// DoAsync starts sending r with the settings of c in a new goroutine and
+// returns right away.
+func (c *Client) DoAsync(r Request) *Future {
+	return startFuture(r, c.Do)
+}
+
+func startFuture(r Request, do func(Request) (*Response, error)) *Future {
+	parent := r.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	r.Context = ctx
+
+	f := &Future{done: make(chan struct{}), cancel: cancel}
+	go func() {
+		res, err := do(r)
+		f.mu.Lock()
+		f.res, f.err = res, err
+		callbacks := f.callbacks
+		f.callbacks = nil
+		close(f.done)
+		f.mu.Unlock()
+		for _, fn := range callbacks {
+			fn(res, err)
+		}
+	}()
+	return f
+}
+
+// Wait blocks until the request is done and returns its result.
+func (f *Future) Wait() (*Response, error) {
+	<-f.done
+	return f.res, f.err
+}
+
+// Done returns a channel that is closed once the request is done.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel aborts the request. It works whether the request is still
+// waiting for the response or its body is being read.
+func (f *Future) Cancel() {
+	f.cancel()
+}
+
+// OnComplete registers fn to be called with the result of the request once
+// it is done. If it already is, fn is called right away.
+func (f *Future) OnComplete(fn func(*Response, error)) {
+	f.mu.Lock()
+	select {
+	case <-f.done:
+		f.mu.Unlock()
+		fn(f.res, f.err)
+	default:
+		f.callbacks = append(f.callbacks, fn)
+		f.mu.Unlock()
+	}
+}
@@ -0,0 +1,89 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestDoAsync(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("DoAsync", func() {
+		var ts *httptest.Server
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/slow" {
+					select {
+					case <-time.After(time.Second):
+					case <-r.Context().Done():
+						return
+					}
+				}
+				if r.URL.Path == "/stream" {
+					fmt.Fprint(w, "Hello")
+					w.(http.Flusher).Flush()
+					<-r.Context().Done()
+					return
+				}
+				fmt.Fprint(w, "bar")
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should return the response once done", func() {
+			f := Request{Uri: ts.URL}.DoAsync()
+			<-f.Done()
+			res, err := f.Wait()
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := res.Body.ToString()
+			gomega.Expect(str).Should(gomega.Equal("bar"))
+		})
+
+		g.It("Should call completion callbacks", func() {
+			results := make(chan string, 2)
+			f := (&Client{}).DoAsync(Request{Uri: ts.URL})
+			f.OnComplete(func(res *Response, err error) {
+				results <- fmt.Sprint(res.StatusCode, err)
+			})
+			f.Wait()
+			f.OnComplete(func(res *Response, err error) {
+				results <- "late"
+			})
+			gomega.Expect(<-results).Should(gomega.Equal("200 <nil>"))
+			gomega.Expect(<-results).Should(gomega.Equal("late"))
+		})
+
+		g.It("Should cancel a request waiting for its response", func() {
+			start := time.Now()
+			f := Request{Uri: ts.URL + "/slow"}.DoAsync()
+			time.Sleep(20 * time.Millisecond)
+			f.Cancel()
+			_, err := f.Wait()
+			gomega.Expect(errors.Is(err, context.Canceled)).Should(gomega.BeTrue())
+			gomega.Expect(time.Since(start)).Should(gomega.BeNumerically("<", 500*time.Millisecond))
+		})
+
+		g.It("Should cancel a request while its body is read", func() {
+			f := Request{Uri: ts.URL + "/stream"}.DoAsync()
+			res, err := f.Wait()
+			gomega.Expect(err).Should(gomega.BeNil())
+			f.Cancel()
+			_, err = ioutil.ReadAll(res.Body)
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+		})
+	})
+}
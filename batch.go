@@ -0,0 +1,156 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Batch runs many requests with a bounded number of them in flight.
+// Response bodies are always buffered (as with BufferBody) so they stay
+// readable once the batch is over.
+type Batch struct {
+	// Concurrency is the maximum number of requests in flight. It defaults
+	// to 10.
+	Concurrency int
+	// FailFast stops the batch at the first request that fails, cancelling
+	// the ones in flight and not starting the rest. Otherwise every request
+	// is run and errors are reported in each BatchResult.
+	FailFast bool
+	// Client, if set, is used to send the requests.
+	Client *Client
+}
+
+// BatchResult is the outcome of one request of a batch. Index is the
+// position of the request in the input.
+type BatchResult struct {
+	Index    int
+	Request  Request
+	Response *Response
+	Err      error
+}
+
+type batchJob struct {
+	index int
+	req   Request
+}
+
+func (b Batch) concurrency() int {
+	if b.Concurrency > 0 {
+		return b.Concurrency
+	}
+	return 10
+}
+
+// Do runs requests and returns their results in the same order. With
+// FailFast the error of the first failed request is returned as well, and
+// requests that were never sent have a context.Canceled error. If ctx is
+// cancelled the outstanding requests are cancelled and ctx.Err() is
+// returned.
+func (b Batch) Do(ctx context.Context, requests []Request) ([]BatchResult, error) {
+	in := make(chan Request, len(requests))
+	for _, r := range requests {
+		in <- r
+	}
+	close(in)
+
+	results := make([]BatchResult, len(requests))
+	for i, r := range requests {
+		results[i] = BatchResult{Index: i, Request: r, Err: context.Canceled}
+	}
+	var failure error
+	for res := range b.Stream(ctx, in) {
+		results[res.Index] = res
+		if res.Err != nil && b.FailFast && (failure == nil || errors.Is(failure, context.Canceled)) {
+			failure = res.Err
+		}
+	}
+	if ctx.Err() != nil {
+		return results, ctx.Err()
+	}
+	return results, failure
+}
+
+// Stream runs the requests received from requests and sends their results
+// as they complete. Index is the order in which requests were received.
+// The returned channel is closed once requests is closed and every
+// request is done, or once ctx is cancelled (or, with FailFast, a request
+// failed) and the requests in flight are done. It must be drained.
+func (b Batch) Stream(ctx context.Context, requests <-chan Request) <-chan BatchResult {
+	ctx, cancel := context.WithCancel(ctx)
+	jobs := make(chan batchJob)
+	results := make(chan BatchResult)
+
+	go func() {
+		defer close(jobs)
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case r, ok := <-requests:
+				if !ok {
+					return
+				}
+				select {
+				case jobs <- batchJob{i, r}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < b.concurrency(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				res := b.run(ctx, job)
+				if res.Err != nil && b.FailFast {
+					cancel()
+				}
+				results <- res
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(results)
+	}()
+	return results
+}
+
+func (b Batch) run(ctx context.Context, job batchJob) BatchResult {
+	r := job.req
+	result := BatchResult{Index: job.index, Request: r}
+	if ctx.Err() != nil {
+		result.Err = ctx.Err()
+		return result
+	}
+	if r.Context != nil {
+		reqCtx, cancel := context.WithCancel(r.Context)
+		defer cancel()
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-ctx.Done():
+				cancel()
+			case <-stop:
+			}
+		}()
+		r.Context = reqCtx
+	} else {
+		r.Context = ctx
+	}
+	r.BufferBody = true
+	if b.Client != nil {
+		result.Response, result.Err = b.Client.Do(r)
+	} else {
+		result.Response, result.Err = r.Do()
+	}
+	return result
+}
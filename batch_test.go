@@ -0,0 +1,134 @@
+package goreq
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestBatch(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Batch", func() {
+		var ts *httptest.Server
+		var inFlight, maxInFlight, hits int32
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					max := atomic.LoadInt32(&maxInFlight)
+					if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+						break
+					}
+				}
+				delay, _ := strconv.Atoi(r.URL.Query().Get("delay"))
+				select {
+				case <-time.After(time.Duration(delay) * time.Millisecond):
+				case <-r.Context().Done():
+					return
+				}
+				if r.URL.Query().Get("fail") != "" {
+					panic(http.ErrAbortHandler)
+				}
+				fmt.Fprint(w, r.URL.Query().Get("id"))
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.BeforeEach(func() {
+			atomic.StoreInt32(&maxInFlight, 0)
+			atomic.StoreInt32(&hits, 0)
+		})
+
+		requests := func(n int, query func(i int) string) []Request {
+			var reqs []Request
+			for i := 0; i < n; i++ {
+				reqs = append(reqs, Request{Uri: fmt.Sprintf("%s/?id=%d&%s", ts.URL, i, query(i))})
+			}
+			return reqs
+		}
+
+		g.It("Should return results in input order with bounded concurrency", func() {
+			reqs := requests(12, func(i int) string { return fmt.Sprintf("delay=%d", (12-i)*3) })
+			results, err := Batch{Concurrency: 3}.Do(context.Background(), reqs)
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(results).Should(gomega.HaveLen(12))
+			for i, res := range results {
+				gomega.Expect(res.Index).Should(gomega.Equal(i))
+				gomega.Expect(res.Err).Should(gomega.BeNil())
+				str, _ := res.Response.Body.ToString()
+				gomega.Expect(str).Should(gomega.Equal(strconv.Itoa(i)))
+			}
+			gomega.Expect(atomic.LoadInt32(&maxInFlight)).Should(gomega.BeNumerically("<=", 3))
+		})
+
+		g.It("Should collect every error by default", func() {
+			reqs := requests(4, func(i int) string {
+				if i%2 == 1 {
+					return "fail=1"
+				}
+				return ""
+			})
+			results, err := Batch{Concurrency: 2}.Do(context.Background(), reqs)
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(results[0].Err).Should(gomega.BeNil())
+			gomega.Expect(results[1].Err).ShouldNot(gomega.BeNil())
+			gomega.Expect(results[2].Err).Should(gomega.BeNil())
+			gomega.Expect(results[3].Err).ShouldNot(gomega.BeNil())
+		})
+
+		g.It("Should stop at the first error if FailFast is set", func() {
+			reqs := requests(20, func(i int) string {
+				if i == 0 {
+					return "fail=1"
+				}
+				return "delay=20"
+			})
+			results, err := Batch{Concurrency: 2, FailFast: true}.Do(context.Background(), reqs)
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			gomega.Expect(results[0].Err).ShouldNot(gomega.BeNil())
+			gomega.Expect(results[19].Err).Should(gomega.Equal(context.Canceled))
+			gomega.Expect(atomic.LoadInt32(&hits)).Should(gomega.BeNumerically("<", 20))
+		})
+
+		g.It("Should cancel outstanding requests when the context is cancelled", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			reqs := requests(10, func(i int) string { return "delay=1000" })
+			start := time.Now()
+			_, err := Batch{Concurrency: 5}.Do(ctx, reqs)
+			gomega.Expect(err).Should(gomega.Equal(context.DeadlineExceeded))
+			gomega.Expect(time.Since(start)).Should(gomega.BeNumerically("<", 500*time.Millisecond))
+		})
+
+		g.It("Should stream results as they complete", func() {
+			in := make(chan Request)
+			go func() {
+				in <- Request{Uri: ts.URL + "/?id=slow&delay=100"}
+				in <- Request{Uri: ts.URL + "/?id=fast"}
+				close(in)
+			}()
+			var order []string
+			for res := range (Batch{Concurrency: 2}).Stream(context.Background(), in) {
+				str, _ := res.Response.Body.ToString()
+				order = append(order, str)
+			}
+			gomega.Expect(order).Should(gomega.Equal([]string{"fast", "slow"}))
+		})
+	})
+}
@@ -0,0 +1,255 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, without sending anything, for requests to a
+// key whose circuit breaker is open.
+var ErrCircuitOpen = errors.New("Circuit breaker is open")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker stops sending requests to a key (by default the request
+// host) that keeps failing. The circuit opens after ConsecutiveFailures
+// failures in a row, or when at least MinRequests were sent in the current
+// Window and FailureRate of them failed. If neither is set the circuit
+// opens after 5 consecutive failures. Once OpenTimeout has passed,
+// HalfOpenRequests probe requests are let through: if they succeed the
+// circuit closes again, otherwise it opens for another OpenTimeout.
+type CircuitBreaker struct {
+	ConsecutiveFailures int
+	FailureRate         float64
+	MinRequests         int
+	// Window is the period over which FailureRate is computed. It defaults
+	// to 10 seconds.
+	Window time.Duration
+	// OpenTimeout defaults to 30 seconds.
+	OpenTimeout time.Duration
+	// HalfOpenRequests defaults to 1.
+	HalfOpenRequests int
+	// IsFailure classifies the result of a request. By default errors and
+	// 5xx responses are failures. Cancelled requests and requests refused
+	// by a RateLimiter are not counted either way.
+	IsFailure func(res *http.Response, err error) bool
+	// Key returns the circuit a request belongs to. It defaults to the
+	// request host.
+	Key func(req *http.Request) string
+	// OnStateChange is called every time a circuit changes state.
+	OnStateChange func(key string, from, to BreakerState)
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state       BreakerState
+	openedAt    time.Time
+	windowStart time.Time
+	requests    int
+	failures    int
+	consecutive int
+	probes      int
+}
+
+type stateChange struct {
+	key      string
+	from, to BreakerState
+}
+
+// State returns the current state of the circuit of key.
+func (b *CircuitBreaker) State(key string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[key]; ok {
+		return c.state
+	}
+	return BreakerClosed
+}
+
+func (b *CircuitBreaker) key(req *http.Request) string {
+	if b.Key != nil {
+		return b.Key(req)
+	}
+	return req.URL.Host
+}
+
+func (b *CircuitBreaker) isFailure(res *http.Response, err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(res, err)
+	}
+	if err != nil {
+		return true
+	}
+	return res.StatusCode >= 500
+}
+
+func (b *CircuitBreaker) consecutiveFailures() int {
+	if b.ConsecutiveFailures == 0 && b.FailureRate == 0 {
+		return 5
+	}
+	return b.ConsecutiveFailures
+}
+
+func (b *CircuitBreaker) window() time.Duration {
+	if b.Window > 0 {
+		return b.Window
+	}
+	return 10 * time.Second
+}
+
+func (b *CircuitBreaker) openTimeout() time.Duration {
+	if b.OpenTimeout > 0 {
+		return b.OpenTimeout
+	}
+	return 30 * time.Second
+}
+
+func (b *CircuitBreaker) halfOpenRequests() int {
+	if b.HalfOpenRequests > 0 {
+		return b.HalfOpenRequests
+	}
+	return 1
+}
+
+func (b *CircuitBreaker) circuit(key string, now time.Time) *circuit {
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{windowStart: now}
+		b.circuits[key] = c
+	}
+	return c
+}
+
+func (b *CircuitBreaker) setState(c *circuit, key string, to BreakerState, now time.Time, changes []stateChange) []stateChange {
+	if c.state == to {
+		return changes
+	}
+	changes = append(changes, stateChange{key, c.state, to})
+	c.state = to
+	c.requests, c.failures, c.consecutive, c.probes = 0, 0, 0, 0
+	c.windowStart = now
+	if to == BreakerOpen {
+		c.openedAt = now
+	}
+	return changes
+}
+
+func (b *CircuitBreaker) notify(changes []stateChange) {
+	if b.OnStateChange == nil {
+		return
+	}
+	for _, change := range changes {
+		b.OnStateChange(change.key, change.from, change.to)
+	}
+}
+
+// allow reports whether a request to key can be sent now.
+func (b *CircuitBreaker) allow(key string, now time.Time) error {
+	var changes []stateChange
+	defer func() { b.notify(changes) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(key, now)
+	if c.state == BreakerOpen {
+		if now.Sub(c.openedAt) < b.openTimeout() {
+			return ErrCircuitOpen
+		}
+		changes = b.setState(c, key, BreakerHalfOpen, now, changes)
+	}
+	if c.state == BreakerHalfOpen {
+		if c.probes >= b.halfOpenRequests() {
+			return ErrCircuitOpen
+		}
+		c.probes++
+	}
+	return nil
+}
+
+// record updates the circuit of key with the result of a request.
+func (b *CircuitBreaker) record(key string, failed bool, now time.Time) {
+	var changes []stateChange
+	defer func() { b.notify(changes) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(key, now)
+	switch c.state {
+	case BreakerHalfOpen:
+		if failed {
+			changes = b.setState(c, key, BreakerOpen, now, changes)
+		} else {
+			changes = b.setState(c, key, BreakerClosed, now, changes)
+		}
+	case BreakerClosed:
+		if now.Sub(c.windowStart) >= b.window() {
+			c.windowStart = now
+			c.requests, c.failures = 0, 0
+		}
+		c.requests++
+		if !failed {
+			c.consecutive = 0
+			return
+		}
+		c.failures++
+		c.consecutive++
+		if n := b.consecutiveFailures(); n > 0 && c.consecutive >= n {
+			changes = b.setState(c, key, BreakerOpen, now, changes)
+		} else if b.FailureRate > 0 && c.requests >= b.MinRequests &&
+			float64(c.failures)/float64(c.requests) >= b.FailureRate {
+			changes = b.setState(c, key, BreakerOpen, now, changes)
+		}
+	}
+}
+
+// release frees the half-open probe slot taken by a request to key whose
+// result is unknown because it was cancelled or rate limited.
+func (b *CircuitBreaker) release(key string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c := b.circuit(key, now); c.state == BreakerHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+}
+
+func (b *CircuitBreaker) roundTrip(transport http.RoundTripper, req *http.Request) (*http.Response, error) {
+	key := b.key(req)
+	if err := b.allow(key, time.Now()); err != nil {
+		return nil, err
+	}
+	res, err := transport.RoundTrip(req)
+	// cancelled requests and requests refused by a RateLimiter never
+	// reached the server, they say nothing about its health
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, ErrRateLimited)) {
+		b.release(key, time.Now())
+		return res, err
+	}
+	b.record(key, b.isFailure(res, err), time.Now())
+	return res, err
+}
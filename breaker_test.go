@@ -0,0 +1,137 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("CircuitBreaker", func() {
+		var ts *httptest.Server
+		var status int
+		var hits int
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				w.WriteHeader(status)
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.BeforeEach(func() {
+			status = 200
+			hits = 0
+		})
+
+		g.It("Should open after consecutive failures and short-circuit requests", func() {
+			var changes []string
+			breaker := &CircuitBreaker{
+				ConsecutiveFailures: 2,
+				OpenTimeout:         time.Hour,
+				OnStateChange: func(key string, from, to BreakerState) {
+					changes = append(changes, from.String()+">"+to.String())
+				},
+			}
+			client := &Client{CircuitBreaker: breaker}
+			status = 503
+			for i := 0; i < 2; i++ {
+				res, err := client.Do(Request{Uri: ts.URL})
+				gomega.Expect(err).Should(gomega.BeNil())
+				res.Body.Close()
+			}
+			u, _ := url.Parse(ts.URL)
+			gomega.Expect(breaker.State(u.Host)).Should(gomega.Equal(BreakerOpen))
+
+			_, err := client.Do(Request{Uri: ts.URL})
+			gomega.Expect(errors.Is(err, ErrCircuitOpen)).Should(gomega.BeTrue())
+			gomega.Expect(hits).Should(gomega.Equal(2))
+			gomega.Expect(changes).Should(gomega.Equal([]string{"closed>open"}))
+		})
+
+		g.It("Should close again after a successful probe", func() {
+			breaker := &CircuitBreaker{ConsecutiveFailures: 1, OpenTimeout: 20 * time.Millisecond}
+			status = 500
+			res, _ := Request{Uri: ts.URL, CircuitBreaker: breaker}.Do()
+			res.Body.Close()
+
+			time.Sleep(30 * time.Millisecond)
+			status = 200
+			res, err := Request{Uri: ts.URL, CircuitBreaker: breaker}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			res.Body.Close()
+			u, _ := url.Parse(ts.URL)
+			gomega.Expect(breaker.State(u.Host)).Should(gomega.Equal(BreakerClosed))
+		})
+
+		g.It("Should open when the failure rate is reached", func() {
+			breaker := &CircuitBreaker{FailureRate: 0.5, MinRequests: 4, OpenTimeout: time.Hour}
+			for _, s := range []int{200, 500, 200, 500} {
+				status = s
+				res, _ := Request{Uri: ts.URL, CircuitBreaker: breaker}.Do()
+				res.Body.Close()
+			}
+			_, err := Request{Uri: ts.URL, CircuitBreaker: breaker}.Do()
+			gomega.Expect(errors.Is(err, ErrCircuitOpen)).Should(gomega.BeTrue())
+		})
+
+		g.It("Should count connection errors as failures", func() {
+			breaker := &CircuitBreaker{ConsecutiveFailures: 1, OpenTimeout: time.Hour}
+			_, err := Request{Uri: "http://.localhost", CircuitBreaker: breaker}.Do()
+			gomega.Expect(errors.Is(err, ErrCircuitOpen)).Should(gomega.BeFalse())
+			_, err = Request{Uri: "http://.localhost", CircuitBreaker: breaker}.Do()
+			gomega.Expect(errors.Is(err, ErrCircuitOpen)).Should(gomega.BeTrue())
+		})
+
+		g.It("Should not count cancelled requests", func() {
+			breaker := &CircuitBreaker{ConsecutiveFailures: 2, OpenTimeout: 20 * time.Millisecond}
+			req, _ := http.NewRequest("GET", ts.URL, nil)
+			failing := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("connection reset")
+			})
+			cancelled := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, &url.Error{Op: "Get", URL: ts.URL, Err: context.Canceled}
+			})
+			breaker.roundTrip(failing, req)
+			breaker.roundTrip(cancelled, req)
+			breaker.roundTrip(failing, req)
+			gomega.Expect(breaker.State(req.URL.Host)).Should(gomega.Equal(BreakerOpen))
+
+			time.Sleep(30 * time.Millisecond)
+			breaker.roundTrip(cancelled, req)
+			gomega.Expect(breaker.State(req.URL.Host)).Should(gomega.Equal(BreakerHalfOpen))
+			res, err := breaker.roundTrip(http.DefaultTransport, req)
+			gomega.Expect(err).Should(gomega.BeNil())
+			res.Body.Close()
+			gomega.Expect(breaker.State(req.URL.Host)).Should(gomega.Equal(BreakerClosed))
+		})
+
+		g.It("Should not count requests refused by the rate limiter", func() {
+			breaker := &CircuitBreaker{ConsecutiveFailures: 3, OpenTimeout: time.Hour}
+			client := &Client{CircuitBreaker: breaker, RateLimiter: &RateLimiter{Rate: 1, Burst: 1, FailFast: true}}
+			res, err := client.Do(Request{Uri: ts.URL})
+			gomega.Expect(err).Should(gomega.BeNil())
+			res.Body.Close()
+			for i := 0; i < 3; i++ {
+				_, err := client.Do(Request{Uri: ts.URL})
+				gomega.Expect(errors.Is(err, ErrRateLimited)).Should(gomega.BeTrue())
+			}
+			u, _ := url.Parse(ts.URL)
+			gomega.Expect(breaker.State(u.Host)).Should(gomega.Equal(BreakerClosed))
+		})
+	})
+}
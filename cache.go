@@ -0,0 +1,409 @@
+package goreq
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CacheStatus int
+
+const (
+	// CacheNone means no cache was involved, either because none was
+	// configured or because the request could not use it.
+	CacheNone CacheStatus = iota
+	// CacheMiss means the response came from the server and, if allowed,
+	// was stored.
+	CacheMiss
+	// CacheHit means a fresh stored response was used without contacting
+	// the server.
+	CacheHit
+	// CacheRevalidated means a stored response was used after the server
+	// confirmed it with a 304 Not Modified.
+	CacheRevalidated
+	// CacheStale means a stale stored response was used, as allowed by
+	// stale-while-revalidate or stale-if-error.
+	CacheStale
+)
+
+func (s CacheStatus) String() string {
+	switch s {
+	case CacheNone:
+		return "none"
+	case CacheMiss:
+		return "miss"
+	case CacheHit:
+		return "hit"
+	case CacheRevalidated:
+		return "revalidated"
+	case CacheStale:
+		return "stale"
+	}
+	return "unknown"
+}
+
+// cacheableStatus are the status codes that can be stored without explicit
+// freshness information.
+var cacheableStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// cacheEntry is what is kept in a CacheStore for every cached URL.
+type cacheEntry struct {
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+	RequestTime  time.Time
+	ResponseTime time.Time
+	// Vary holds the values of the request headers listed in the Vary
+	// response header.
+	Vary map[string]string
+	// Credentials is a digest of the credential headers of the request, so
+	// a response is only served to the user it was sent to.
+	Credentials string
+}
+
+// cacheTransport implements a private HTTP cache as described by RFC 9111
+// for GET requests.
+type cacheTransport struct {
+	store CacheStore
+	next  http.RoundTripper
+	ex    *exchange
+}
+
+func cacheKey(req *http.Request) string {
+	return req.URL.String()
+}
+
+// parseCacheControl returns the directives of the Cache-Control headers,
+// lower cased, with their (unquoted) values.
+func parseCacheControl(header http.Header) map[string]string {
+	cc := map[string]string{}
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, value := part, ""
+			if i := strings.Index(part, "="); i >= 0 {
+				name, value = part[:i], strings.Trim(strings.TrimSpace(part[i+1:]), `"`)
+			}
+			cc[strings.ToLower(strings.TrimSpace(name))] = value
+		}
+	}
+	return cc
+}
+
+// seconds returns the value of a delta-seconds directive.
+func seconds(cc map[string]string, name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+func (e *cacheEntry) date() time.Time {
+	if d, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return d
+	}
+	return e.ResponseTime
+}
+
+// age is the current age of the entry (RFC 9111 section 4.2.3).
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparent := e.ResponseTime.Sub(e.date())
+	if apparent < 0 {
+		apparent = 0
+	}
+	corrected := e.ResponseTime.Sub(e.RequestTime)
+	if n, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil {
+		corrected += time.Duration(n) * time.Second
+	}
+	if apparent > corrected {
+		corrected = apparent
+	}
+	return corrected + now.Sub(e.ResponseTime)
+}
+
+// freshness is the freshness lifetime of the entry (RFC 9111 section 4.2.1).
+func (e *cacheEntry) freshness() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if maxAge, ok := seconds(cc, "max-age"); ok {
+		return maxAge
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(e.date())
+	}
+	// heuristic freshness, 10% of the time since the last modification
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && cacheableStatus[e.StatusCode] {
+		if d := e.date().Sub(lastModified); d > 0 {
+			return d / 10
+		}
+	}
+	return 0
+}
+
+// credentials returns a digest of the credential headers of req, or "" if
+// it has none.
+func credentials(req *http.Request) string {
+	var values string
+	for _, name := range credentialHeaders {
+		if v := req.Header.Values(name); len(v) > 0 {
+			values += name + ": " + strings.Join(v, ",") + "\n"
+		}
+	}
+	if values == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(values))
+	return hex.EncodeToString(sum[:])
+}
+
+func (e *cacheEntry) matches(req *http.Request) bool {
+	if e.Credentials != credentials(req) {
+		return false
+	}
+	for name, value := range e.Vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *cacheEntry) response(req *http.Request, age time.Duration) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func (t *cacheTransport) load(req *http.Request) *cacheEntry {
+	data, ok := t.store.Get(cacheKey(req))
+	if !ok {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || !entry.matches(req) {
+		return nil
+	}
+	return &entry
+}
+
+func (t *cacheTransport) save(req *http.Request, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err == nil {
+		t.store.Set(cacheKey(req), data)
+	}
+}
+
+// storable reports whether res can be stored for req.
+func storable(req *http.Request, res *http.Response) bool {
+	if _, ok := parseCacheControl(req.Header)["no-store"]; ok {
+		return false
+	}
+	cc := parseCacheControl(res.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if strings.TrimSpace(res.Header.Get("Vary")) == "*" {
+		return false
+	}
+	_, maxAge := cc["max-age"]
+	explicit := maxAge || res.Header.Get("Expires") != ""
+	if !explicit && !cacheableStatus[res.StatusCode] {
+		return false
+	}
+	return explicit || res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != ""
+}
+
+// keep reads the body of res and keeps it in the cache if it is allowed
+// to. The returned response can be read as if it was res.
+func (t *cacheTransport) keep(req *http.Request, res *http.Response, requestTime time.Time) (*http.Response, error) {
+	if !storable(req, res) {
+		return res, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	entry := &cacheEntry{
+		StatusCode:   res.StatusCode,
+		Header:       res.Header.Clone(),
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: time.Now(),
+		Vary:         map[string]string{},
+		Credentials:  credentials(req),
+	}
+	for _, line := range res.Header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+				entry.Vary[name] = req.Header.Get(name)
+			}
+		}
+	}
+	t.save(req, entry)
+	return res, nil
+}
+
+// revalidate sends a conditional request for entry and returns the
+// response. A 304 is merged into entry and stored again.
+func (t *cacheTransport) revalidate(req *http.Request, entry *cacheEntry) (*http.Response, error) {
+	creq := req.Clone(req.Context())
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		creq.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+		creq.Header.Set("If-Modified-Since", lastModified)
+	}
+	requestTime := time.Now()
+	res, err := t.next.RoundTrip(creq)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusNotModified {
+		return t.keep(req, res, requestTime)
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	for name, values := range res.Header {
+		if name != "Content-Length" {
+			entry.Header[name] = values
+		}
+	}
+	entry.RequestTime = requestTime
+	entry.ResponseTime = time.Now()
+	t.save(req, entry)
+	return res, nil
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		res, err := t.next.RoundTrip(req)
+		if err == nil && req.Method != "HEAD" && res.StatusCode < 400 {
+			t.invalidate(req, res)
+		}
+		return res, err
+	}
+	// requests that are already conditional or partial are left alone
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" || req.Header.Get("Range") != "" {
+		t.ex.cacheStatus = CacheNone
+		return t.next.RoundTrip(req)
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		t.ex.cacheStatus = CacheNone
+		return t.next.RoundTrip(req)
+	}
+
+	entry := t.load(req)
+	if entry == nil {
+		t.ex.cacheStatus = CacheMiss
+		requestTime := time.Now()
+		res, err := t.next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		return t.keep(req, res, requestTime)
+	}
+
+	now := time.Now()
+	resCC := parseCacheControl(entry.Header)
+	age, lifetime := entry.age(now), entry.freshness()
+	if maxAge, ok := seconds(reqCC, "max-age"); ok && maxAge < lifetime {
+		lifetime = maxAge
+	}
+	_, reqNoCache := reqCC["no-cache"]
+	_, resNoCache := resCC["no-cache"]
+	_, mustRevalidate := resCC["must-revalidate"]
+	canServeStale := !reqNoCache && !resNoCache && !mustRevalidate
+	staleness := age - lifetime
+
+	if !reqNoCache && !resNoCache && staleness < 0 {
+		t.ex.cacheStatus = CacheHit
+		return entry.response(req, age), nil
+	}
+
+	if window, ok := seconds(resCC, "stale-while-revalidate"); ok && canServeStale && staleness < window {
+		res := entry.response(req, age)
+		go func() {
+			res, err := t.revalidate(req.Clone(context.Background()), entry)
+			if err == nil {
+				io.Copy(ioutil.Discard, res.Body)
+				res.Body.Close()
+			}
+		}()
+		t.ex.cacheStatus = CacheStale
+		return res, nil
+	}
+
+	res, err := t.revalidate(req, entry)
+	if err != nil || res.StatusCode >= 500 {
+		window, ok := seconds(resCC, "stale-if-error")
+		if reqWindow, reqOk := seconds(reqCC, "stale-if-error"); reqOk {
+			window, ok = reqWindow, true
+		}
+		if ok && canServeStale && staleness < window {
+			if res != nil {
+				res.Body.Close()
+			}
+			t.ex.cacheStatus = CacheStale
+			return entry.response(req, age), nil
+		}
+		t.ex.cacheStatus = CacheMiss
+		return res, err
+	}
+	if res.StatusCode == http.StatusNotModified {
+		t.ex.cacheStatus = CacheRevalidated
+		return entry.response(req, entry.age(time.Now())), nil
+	}
+	t.ex.cacheStatus = CacheMiss
+	return res, nil
+}
+
+// invalidate drops the stored responses for the URL of an unsafe request
+// and for its Location and Content-Location.
+func (t *cacheTransport) invalidate(req *http.Request, res *http.Response) {
+	t.store.Delete(cacheKey(req))
+	for _, name := range []string{"Location", "Content-Location"} {
+		if loc := res.Header.Get(name); loc != "" {
+			if u, err := req.URL.Parse(loc); err == nil && u.Host == req.URL.Host {
+				t.store.Delete(u.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package goreq
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStore keeps the responses stored by the HTTP cache. Keys are
+// request URLs and values are opaque serialized responses.
+// Implementations must be safe for concurrent use.
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// MemoryCache is an in-memory CacheStore that evicts the least recently
+// used entries once it holds more than MaxEntries of them.
+type MemoryCache struct {
+	// MaxEntries is the maximum number of entries kept. Zero means no
+	// limit.
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type memoryCacheItem struct {
+	key   string
+	value []byte
+}
+
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{MaxEntries: maxEntries}
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*memoryCacheItem).value, true
+}
+
+func (c *MemoryCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+		c.lru = list.New()
+	}
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*memoryCacheItem).value = value
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&memoryCacheItem{key, value})
+	for c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryCacheItem).key)
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// DiskCache is a CacheStore that keeps every entry in its own file inside
+// Dir. Files are written to a temporary name and renamed, so readers never
+// see partial entries.
+type DiskCache struct {
+	Dir string
+}
+
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{Dir: dir}
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	value, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *DiskCache) Set(key string, value []byte) {
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(c.Dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+func (c *DiskCache) Delete(key string) {
+	os.Remove(c.path(key))
+}
@@ -0,0 +1,196 @@
+package goreq
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestCache(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("HTTP cache", func() {
+		var ts *httptest.Server
+		var hits int32
+		var failing int32
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&hits, 1)
+				if r.Method == "POST" {
+					w.WriteHeader(204)
+					return
+				}
+				if atomic.LoadInt32(&failing) == 1 {
+					w.WriteHeader(500)
+					return
+				}
+				switch r.URL.Path {
+				case "/max-age":
+					w.Header().Set("Cache-Control", "max-age=60")
+				case "/etag":
+					w.Header().Set("Cache-Control", "no-cache")
+					w.Header().Set("ETag", `"v1"`)
+					if r.Header.Get("If-None-Match") == `"v1"` {
+						w.WriteHeader(304)
+						return
+					}
+				case "/vary":
+					w.Header().Set("Cache-Control", "max-age=60")
+					w.Header().Set("Vary", "Accept-Language")
+				case "/no-store":
+					w.Header().Set("Cache-Control", "no-store")
+				case "/stale-if-error":
+					w.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+				case "/stale-while-revalidate":
+					w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+				}
+				w.WriteHeader(200)
+				fmt.Fprintf(w, "response %d", n)
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.BeforeEach(func() {
+			atomic.StoreInt32(&hits, 0)
+			atomic.StoreInt32(&failing, 0)
+		})
+
+		get := func(client *Client, path string) (*Response, string) {
+			res, err := client.Do(Request{Uri: ts.URL + path})
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := res.Body.ToString()
+			return res, str
+		}
+
+		g.It("Should serve fresh responses from the cache", func() {
+			client := &Client{Cache: NewMemoryCache(0)}
+			res, str := get(client, "/max-age")
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheMiss))
+			gomega.Expect(str).Should(gomega.Equal("response 1"))
+
+			res, str = get(client, "/max-age")
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheHit))
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+			gomega.Expect(str).Should(gomega.Equal("response 1"))
+			gomega.Expect(atomic.LoadInt32(&hits)).Should(gomega.Equal(int32(1)))
+		})
+
+		g.It("Should revalidate with ETag", func() {
+			client := &Client{Cache: NewMemoryCache(0)}
+			get(client, "/etag")
+			res, str := get(client, "/etag")
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheRevalidated))
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+			gomega.Expect(str).Should(gomega.Equal("response 1"))
+			gomega.Expect(atomic.LoadInt32(&hits)).Should(gomega.Equal(int32(2)))
+		})
+
+		g.It("Should honor Vary", func() {
+			client := &Client{Cache: NewMemoryCache(0)}
+			client.Do(Request{Uri: ts.URL + "/vary"}.WithHeader("Accept-Language", "en"))
+			res, _ := client.Do(Request{Uri: ts.URL + "/vary"}.WithHeader("Accept-Language", "fr"))
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheMiss))
+			res, _ = client.Do(Request{Uri: ts.URL + "/vary"}.WithHeader("Accept-Language", "fr"))
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheHit))
+		})
+
+		g.It("Should not serve a response to another user", func() {
+			client := &Client{Cache: NewMemoryCache(0)}
+			alice := Request{Uri: ts.URL + "/max-age"}.WithHeader("Authorization", "Bearer alice")
+			bob := Request{Uri: ts.URL + "/max-age"}.WithHeader("Authorization", "Bearer bob")
+			res, _ := client.Do(alice)
+			gomega.Expect(res.Body.ToString()).Should(gomega.Equal("response 1"))
+			res, _ = client.Do(bob)
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheMiss))
+			gomega.Expect(res.Body.ToString()).Should(gomega.Equal("response 2"))
+			res, _ = client.Do(bob)
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheHit))
+			res, _ = client.Do(Request{Uri: ts.URL + "/max-age"})
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheMiss))
+		})
+
+		g.It("Should not store no-store responses", func() {
+			cache := NewMemoryCache(0)
+			get(&Client{Cache: cache}, "/no-store")
+			gomega.Expect(cache.Len()).Should(gomega.Equal(0))
+		})
+
+		g.It("Should serve stale responses on errors if stale-if-error allows it", func() {
+			client := &Client{Cache: NewMemoryCache(0)}
+			get(client, "/stale-if-error")
+			atomic.StoreInt32(&failing, 1)
+			res, str := get(client, "/stale-if-error")
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheStale))
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+			gomega.Expect(str).Should(gomega.Equal("response 1"))
+		})
+
+		g.It("Should revalidate in the background if stale-while-revalidate allows it", func() {
+			client := &Client{Cache: NewMemoryCache(0)}
+			get(client, "/stale-while-revalidate")
+			res, str := get(client, "/stale-while-revalidate")
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheStale))
+			gomega.Expect(str).Should(gomega.Equal("response 1"))
+			gomega.Eventually(func() int32 { return atomic.LoadInt32(&hits) }).Should(gomega.Equal(int32(2)))
+		})
+
+		g.It("Should invalidate stored responses on unsafe requests", func() {
+			client := &Client{Cache: NewMemoryCache(0)}
+			get(client, "/max-age")
+			client.Do(Request{Method: "POST", Uri: ts.URL + "/max-age"})
+			res, _ := get(client, "/max-age")
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheMiss))
+		})
+
+		g.It("Should work with a DiskCache", func() {
+			dir, _ := ioutil.TempDir("", "goreq-cache")
+			defer os.RemoveAll(dir)
+			get(&Client{Cache: NewDiskCache(dir)}, "/max-age")
+			res, str := get(&Client{Cache: NewDiskCache(dir)}, "/max-age")
+			gomega.Expect(res.CacheStatus).Should(gomega.Equal(CacheHit))
+			gomega.Expect(str).Should(gomega.Equal("response 1"))
+		})
+	})
+
+	g.Describe("MemoryCache", func() {
+		g.It("Should evict the least recently used entries", func() {
+			cache := NewMemoryCache(2)
+			cache.Set("a", []byte("a"))
+			cache.Set("b", []byte("b"))
+			cache.Get("a")
+			cache.Set("c", []byte("c"))
+			_, ok := cache.Get("b")
+			gomega.Expect(ok).Should(gomega.BeFalse())
+			_, ok = cache.Get("a")
+			gomega.Expect(ok).Should(gomega.BeTrue())
+			gomega.Expect(cache.Len()).Should(gomega.Equal(2))
+		})
+	})
+
+	g.Describe("Cache freshness", func() {
+		g.It("Should compute freshness from Expires and Last-Modified", func() {
+			now := time.Now().UTC()
+			entry := &cacheEntry{StatusCode: 200, Header: http.Header{}, ResponseTime: now}
+			entry.Header.Set("Date", now.Format(http.TimeFormat))
+			entry.Header.Set("Expires", now.Add(time.Minute).Format(http.TimeFormat))
+			gomega.Expect(entry.freshness()).Should(gomega.Equal(time.Minute))
+
+			entry.Header.Del("Expires")
+			entry.Header.Set("Last-Modified", now.Add(-100*time.Minute).Format(http.TimeFormat))
+			gomega.Expect(entry.freshness()).Should(gomega.Equal(10 * time.Minute))
+		})
+	})
+}
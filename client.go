@@ -0,0 +1,182 @@
+package goreq
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Client holds settings shared by all the requests sent through it, like
+// rate limiting. Settings set on a Request take precedence over the ones
+// of its Client. A Client is safe for concurrent use and its zero value
+// behaves like the package defaults. A Client must not be copied once
+// used.
+type Client struct {
+	// counters comes first to be 64-bit aligned for atomic operations
+	counters poolCounters
+
+	RateLimiter    *RateLimiter
+	CircuitBreaker *CircuitBreaker
+	Cache          CacheStore
+	Coalescer      *Coalescer
+	Hedging        *Hedging
+	// TLS applies to the requests without TLS settings of their own. The
+	// Client keeps a transport per *TLSConfig it sees, see TLSConfig.
+	TLS *TLSConfig
+	// ProxyPool, if set, picks the proxy of requests without a Proxy.
+	ProxyPool *ProxyPool
+	// SocketPath, if set, is a Unix socket every request is sent through,
+	// like /var/run/docker.sock.
+	SocketPath string
+	// DNS, if set, controls how host names are resolved.
+	DNS *DNS
+	// SSRFGuard, if set, refuses connections to internal networks.
+	SSRFGuard *SSRFGuard
+	Pool      PoolConfig
+	Protocol  Protocol
+	// PhaseTimeouts applies to the requests without PhaseTimeouts of their
+	// own.
+	PhaseTimeouts PhaseTimeouts
+
+	transports sync.Map
+}
+
+// Do sends r with the settings of c.
+func (c *Client) Do(r Request) (*Response, error) {
+	r.client = c
+	return r.Do()
+}
+
+// exchange collects what the transport layers learn while sending a
+// request, to be reported on its Response.
+type exchange struct {
+	cacheStatus  CacheStatus
+	hedgeAttempt int
+	// sharedBody is set when the copies of the request body share the
+	// position of a single io.ReadSeeker.
+	sharedBody bool
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func (r Request) rateLimiter() *RateLimiter {
+	if r.RateLimiter == nil && r.client != nil {
+		return r.client.RateLimiter
+	}
+	return r.RateLimiter
+}
+
+func (r Request) circuitBreaker() *CircuitBreaker {
+	if r.CircuitBreaker == nil && r.client != nil {
+		return r.client.CircuitBreaker
+	}
+	return r.CircuitBreaker
+}
+
+func (r Request) cache() CacheStore {
+	if r.Cache == nil && r.client != nil {
+		return r.client.Cache
+	}
+	return r.Cache
+}
+
+func (r Request) coalescer() *Coalescer {
+	if r.Coalescer == nil && r.client != nil {
+		return r.client.Coalescer
+	}
+	return r.Coalescer
+}
+
+func (r Request) hedging() *Hedging {
+	if r.Hedging == nil && r.client != nil {
+		return r.client.Hedging
+	}
+	return r.Hedging
+}
+
+func (r Request) tlsConfig() *TLSConfig {
+	if r.TLS == nil && r.client != nil {
+		return r.client.TLS
+	}
+	return r.TLS
+}
+
+func (r Request) dns() *DNS {
+	if r.client != nil {
+		return r.client.DNS
+	}
+	return nil
+}
+
+func (r Request) ssrfGuard() *SSRFGuard {
+	if r.client != nil {
+		return r.client.SSRFGuard
+	}
+	return nil
+}
+
+func (r Request) pool() PoolConfig {
+	if r.client != nil {
+		return r.client.Pool
+	}
+	return PoolConfig{}
+}
+
+func (r Request) protocol() Protocol {
+	if r.client != nil {
+		return r.client.Protocol
+	}
+	return DefaultProtocol
+}
+
+func (r Request) phaseTimeouts() PhaseTimeouts {
+	if r.PhaseTimeouts == (PhaseTimeouts{}) && r.client != nil {
+		return r.client.PhaseTimeouts
+	}
+	return r.PhaseTimeouts
+}
+
+// wrapTransport adds the per hop behaviour configured in r and its client
+// around transport. It is applied to every request of a redirect chain.
+func (r Request) wrapTransport(transport http.RoundTripper, ex *exchange) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if timeouts := r.phaseTimeouts(); timeouts != (PhaseTimeouts{}) {
+		next := transport
+		transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return timeouts.roundTrip(next, req)
+		})
+	}
+	if limiter := r.rateLimiter(); limiter != nil {
+		next := transport
+		transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return limiter.roundTrip(next, req)
+		})
+	}
+	if breaker := r.circuitBreaker(); breaker != nil {
+		next := transport
+		transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return breaker.roundTrip(next, req)
+		})
+	}
+	if hedging := r.hedging(); hedging != nil {
+		next := transport
+		transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return hedging.roundTrip(next, req, ex)
+		})
+	}
+	if coalescer := r.coalescer(); coalescer != nil {
+		next := transport
+		transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return coalescer.roundTrip(next, req)
+		})
+	}
+	if store := r.cache(); store != nil {
+		transport = &cacheTransport{store: store, next: transport, ex: ex}
+	}
+	return transport
+}
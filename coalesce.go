@@ -0,0 +1,92 @@
+package goreq
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Coalescer makes identical GET and HEAD requests that are in flight at the
+// same time share a single round trip. Requests are identical when they
+// have the same method, URL, credentials (Authorization and Cookie headers,
+// among others) and values for the headers listed in Headers. The response
+// body is read once and every caller gets its own Response with a copy of
+// it.
+//
+// The shared round trip is made with the context of the first request, so
+// if it is cancelled the requests waiting on it fail too.
+type Coalescer struct {
+	Headers []string
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done chan struct{}
+	res  *http.Response
+	body []byte
+	err  error
+}
+
+// key always includes the credential headers so callers never get the
+// response to somebody else's credentials.
+func (c *Coalescer) key(req *http.Request) string {
+	key := req.Method + " " + req.URL.String()
+	for _, name := range append(credentialHeaders[:len(credentialHeaders):len(credentialHeaders)], c.Headers...) {
+		key += "\n" + http.CanonicalHeaderKey(name) + ": " + strings.Join(req.Header.Values(name), ",")
+	}
+	return key
+}
+
+func (c *Coalescer) roundTrip(transport http.RoundTripper, req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return transport.RoundTrip(req)
+	}
+	key := c.key(req)
+
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.response(req)
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if c.calls == nil {
+		c.calls = make(map[string]*coalescedCall)
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	call.res, call.err = transport.RoundTrip(req)
+	if call.err == nil {
+		call.body, call.err = ioutil.ReadAll(call.res.Body)
+		call.res.Body.Close()
+	}
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.response(req)
+}
+
+// response returns a copy of the shared response for req.
+func (call *coalescedCall) response(req *http.Request) (*http.Response, error) {
+	if call.err != nil {
+		return nil, call.err
+	}
+	res := *call.res
+	res.Header = call.res.Header.Clone()
+	res.Trailer = call.res.Trailer.Clone()
+	res.Body = ioutil.NopCloser(bytes.NewReader(call.body))
+	res.Request = req
+	return &res, nil
+}
@@ -0,0 +1,126 @@
+package goreq
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestCoalescer(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Coalescer", func() {
+		var ts *httptest.Server
+		var hits int32
+		var release chan bool
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&hits, 1)
+				if r.URL.Path == "/slow" {
+					<-release
+				}
+				w.Header().Set("X-Hit", fmt.Sprint(n))
+				fmt.Fprintf(w, "%s %s", r.Method, r.Header.Get("X-Tenant"))
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.BeforeEach(func() {
+			atomic.StoreInt32(&hits, 0)
+			release = make(chan bool)
+		})
+
+		g.It("Should share one round trip between identical concurrent requests", func() {
+			client := &Client{Coalescer: &Coalescer{}}
+			var wg sync.WaitGroup
+			bodies := make([]string, 5)
+			responses := make([]*Response, 5)
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					res, err := client.Do(Request{Uri: ts.URL + "/slow"})
+					if err == nil {
+						responses[i] = res
+						bodies[i], _ = res.Body.ToString()
+					}
+				}(i)
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			gomega.Expect(atomic.LoadInt32(&hits)).Should(gomega.Equal(int32(1)))
+			for i := 0; i < 5; i++ {
+				gomega.Expect(bodies[i]).Should(gomega.Equal("GET "))
+				gomega.Expect(responses[i].Header.Get("X-Hit")).Should(gomega.Equal("1"))
+			}
+			responses[0].Header.Set("X-Hit", "changed")
+			gomega.Expect(responses[1].Header.Get("X-Hit")).Should(gomega.Equal("1"))
+		})
+
+		g.It("Should not share round trips of requests with different selected headers", func() {
+			coalescer := &Coalescer{Headers: []string{"X-Tenant"}}
+			var wg sync.WaitGroup
+			bodies := make([]string, 2)
+			for i, tenant := range []string{"a", "b"} {
+				wg.Add(1)
+				go func(i int, tenant string) {
+					defer wg.Done()
+					res, err := Request{Uri: ts.URL + "/slow", Coalescer: coalescer}.WithHeader("X-Tenant", tenant).Do()
+					if err == nil {
+						bodies[i], _ = res.Body.ToString()
+					}
+				}(i, tenant)
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			gomega.Expect(atomic.LoadInt32(&hits)).Should(gomega.Equal(int32(2)))
+			gomega.Expect(bodies).Should(gomega.Equal([]string{"GET a", "GET b"}))
+		})
+
+		g.It("Should not share round trips of requests with different credentials", func() {
+			coalescer := &Coalescer{}
+			var wg sync.WaitGroup
+			for i, header := range []string{"Authorization", "Authorization", "Cookie"} {
+				wg.Add(1)
+				go func(i int, header string) {
+					defer wg.Done()
+					res, err := Request{Uri: ts.URL + "/slow", Coalescer: coalescer}.WithHeader(header, fmt.Sprint(i)).Do()
+					if err == nil {
+						res.Body.Close()
+					}
+				}(i, header)
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			gomega.Expect(atomic.LoadInt32(&hits)).Should(gomega.Equal(int32(3)))
+		})
+
+		g.It("Should not coalesce other methods", func() {
+			coalescer := &Coalescer{}
+			for i := 0; i < 2; i++ {
+				res, err := Request{Method: "POST", Uri: ts.URL, Coalescer: coalescer}.Do()
+				gomega.Expect(err).Should(gomega.BeNil())
+				res.Body.Close()
+			}
+			gomega.Expect(atomic.LoadInt32(&hits)).Should(gomega.Equal(int32(2)))
+		})
+	})
+}
@@ -0,0 +1,147 @@
+package goreq
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// IPPreference orders or filters the addresses a host name resolves to.
+type IPPreference int
+
+const (
+	// AnyIP keeps the order of the resolver.
+	AnyIP IPPreference = iota
+	IPv4First
+	IPv6First
+	IPv4Only
+	IPv6Only
+)
+
+// DNS controls how the host names of a Client are resolved. Only the
+// address connections are made to changes: TLS server names and Host
+// headers still come from the URL.
+type DNS struct {
+	// Hosts maps host names to the addresses to use instead of resolving
+	// them, like curl --resolve. Keys are either "host:port", for a single
+	// port, or "host".
+	Hosts map[string][]string
+	// Resolver, if set, resolves the other host names instead of
+	// net.DefaultResolver, for example to query a specific DNS server.
+	Resolver *net.Resolver
+	// TTL, if set, is how long resolved addresses are cached.
+	TTL    time.Duration
+	Prefer IPPreference
+
+	mu    sync.Mutex
+	cache map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// Lookup returns the addresses to connect to for host and port, in the
+// order they should be tried.
+func (d *DNS) Lookup(ctx context.Context, host, port string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	for _, key := range []string{net.JoinHostPort(host, port), host} {
+		if addrs, ok := d.Hosts[key]; ok {
+			var ips []net.IP
+			for _, addr := range addrs {
+				ip := net.ParseIP(addr)
+				if ip == nil {
+					return nil, fmt.Errorf("Invalid address %q for host %s", addr, key)
+				}
+				ips = append(ips, ip)
+			}
+			return d.order(ips, host)
+		}
+	}
+
+	if d.TTL > 0 {
+		d.mu.Lock()
+		entry, ok := d.cache[host]
+		d.mu.Unlock()
+		if ok && time.Now().Before(entry.expires) {
+			return d.order(entry.ips, host)
+		}
+	}
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	if d.TTL > 0 {
+		d.mu.Lock()
+		if d.cache == nil {
+			d.cache = make(map[string]dnsEntry)
+		}
+		d.cache[host] = dnsEntry{ips: ips, expires: time.Now().Add(d.TTL)}
+		d.mu.Unlock()
+	}
+	return d.order(ips, host)
+}
+
+func (d *DNS) order(ips []net.IP, host string) ([]net.IP, error) {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	var ordered []net.IP
+	switch d.Prefer {
+	case IPv4First:
+		ordered = append(v4, v6...)
+	case IPv6First:
+		ordered = append(v6, v4...)
+	case IPv4Only:
+		ordered = v4
+	case IPv6Only:
+		ordered = v6
+	default:
+		ordered = ips
+	}
+	if len(ordered) == 0 {
+		return nil, fmt.Errorf("No suitable address found for %s", host)
+	}
+	return ordered, nil
+}
+
+// dialer returns a dial function connecting with dial to the addresses
+// looked up by d, trying them in turn.
+func (d *DNS) dialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := d.Lookup(ctx, host, port)
+		if err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+		for _, ip := range ips {
+			var conn net.Conn
+			conn, err = dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil || ctx.Err() != nil {
+				return conn, err
+			}
+		}
+		return nil, err
+	}
+}
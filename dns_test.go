@@ -0,0 +1,135 @@
+package goreq
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+// dnsServer answers every A query with 127.0.0.1 and counts them.
+func dnsServer(queries *int32) net.PacketConn {
+	conn, _ := net.ListenPacket("udp", "127.0.0.1:0")
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// the question ends with the name, its type and its class
+			end := 12
+			for buf[end] != 0 {
+				end += int(buf[end]) + 1
+			}
+			end += 5
+			qtype := int(buf[end-4])<<8 | int(buf[end-3])
+			res := append([]byte{buf[0], buf[1], 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0}, buf[12:end]...)
+			if qtype == 1 {
+				atomic.AddInt32(queries, 1)
+				res[7] = 1
+				res = append(res, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+			}
+			conn.WriteTo(res, addr)
+		}
+	}()
+	return conn
+}
+
+func TestDNS(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("DNS", func() {
+		var ts, tlsServer *httptest.Server
+		var port, tlsPort string
+
+		g.Before(func() {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Host))
+			})
+			ts = httptest.NewServer(handler)
+			tlsServer = httptest.NewTLSServer(handler)
+			_, port, _ = net.SplitHostPort(ts.Listener.Addr().String())
+			_, tlsPort, _ = net.SplitHostPort(tlsServer.Listener.Addr().String())
+		})
+
+		g.After(func() {
+			ts.Close()
+			tlsServer.Close()
+		})
+
+		g.It("Should connect to static addresses and keep the Host header", func() {
+			client := &Client{DNS: &DNS{Hosts: map[string][]string{"api.test:" + port: {"127.0.0.1"}}}}
+			res, err := client.Do(Request{Uri: "http://api.test:" + port})
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := res.Body.ToString()
+			gomega.Expect(str).Should(gomega.Equal("api.test:" + port))
+		})
+
+		g.It("Should keep the TLS server name", func() {
+			roots := x509.NewCertPool()
+			roots.AddCert(tlsServer.Certificate())
+			client := &Client{
+				DNS: &DNS{Hosts: map[string][]string{"example.com": {"127.0.0.1"}}},
+				TLS: &TLSConfig{RootCAs: roots},
+			}
+			res, err := client.Do(Request{Uri: "https://example.com:" + tlsPort})
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+		})
+
+		g.It("Should try the addresses in turn", func() {
+			// nothing listens on 127.0.0.2
+			client := &Client{DNS: &DNS{Hosts: map[string][]string{"api.test": {"127.0.0.2", "127.0.0.1"}}}}
+			res, err := client.Do(Request{Uri: "http://api.test:" + port})
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+		})
+
+		g.It("Should order and filter addresses by preference", func() {
+			dns := &DNS{Hosts: map[string][]string{"api.test": {"::1", "127.0.0.1"}}, Prefer: IPv4First}
+			ips, _ := dns.Lookup(context.Background(), "api.test", "80")
+			gomega.Expect(ips[0].String()).Should(gomega.Equal("127.0.0.1"))
+
+			dns.Prefer = IPv6Only
+			ips, _ = dns.Lookup(context.Background(), "api.test", "80")
+			gomega.Expect(ips).Should(gomega.HaveLen(1))
+			gomega.Expect(ips[0].String()).Should(gomega.Equal("::1"))
+
+			dns.Hosts["api.test"] = []string{"127.0.0.1"}
+			_, err := dns.Lookup(context.Background(), "api.test", "80")
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+		})
+
+		g.It("Should use a custom resolver and cache its answers", func() {
+			var queries int32
+			server := dnsServer(&queries)
+			defer server.Close()
+			resolver := &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "udp", server.LocalAddr().String())
+				},
+			}
+			client := &Client{DNS: &DNS{Resolver: resolver, TTL: time.Minute, Prefer: IPv4Only}}
+			for i := 0; i < 2; i++ {
+				res, err := client.Do(Request{Uri: "http://service.internal:" + port})
+				gomega.Expect(err).Should(gomega.BeNil())
+				str, _ := res.Body.ToString()
+				gomega.Expect(str).Should(gomega.Equal("service.internal:" + port))
+				// a new connection for every request
+				res.Body.Close()
+				client.CloseIdleConnections()
+			}
+			gomega.Expect(atomic.LoadInt32(&queries)).Should(gomega.Equal(int32(1)))
+		})
+	})
+}
@@ -0,0 +1,343 @@
+package goreq
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrChecksumMismatch is returned when a downloaded file does not have
+	// the expected digest. The file is removed.
+	ErrChecksumMismatch = errors.New("Downloaded file does not match its checksum")
+	// ErrSizeMismatch is returned when a downloaded file does not have the
+	// size announced by the server. The file is removed.
+	ErrSizeMismatch = errors.New("Downloaded file does not match its Content-Length")
+)
+
+// Download fetches a file to disk. Data is written to a temporary file next
+// to the destination, renamed once complete and verified. If a previous
+// attempt left a temporary file, the download resumes where it stopped
+// with a Range request, using If-Range so that a file that changed on the
+// server is downloaded again from the start.
+type Download struct {
+	// Request is the request of the file, sent as a GET.
+	Request Request
+	// Path is the destination file. If it is an existing directory the file
+	// is saved in it, named after the Content-Disposition header or the URL,
+	// with a number added to the name rather than replacing an existing
+	// file.
+	Path string
+	// Progress, if set, is called as data is written with the number of
+	// bytes of the file written so far and its total size, or -1 if it is
+	// unknown.
+	Progress func(written, total int64)
+	// SHA256 and MD5, if set, are the hex encoded digests the file must
+	// have.
+	SHA256 string
+	MD5    string
+	// Segments, if more than 1, splits the file in as many parts fetched
+	// concurrently with Range requests, if the server supports them.
+	// Segmented downloads are not resumed.
+	Segments int
+	// SegmentRetries is how many times a failed segment is fetched again.
+	// It defaults to 3.
+	SegmentRetries int
+	// Client, if set, is used to send the requests.
+	Client *Client
+}
+
+// Do downloads the file and returns the path it was saved to.
+func (d Download) Do(ctx context.Context) (string, error) {
+	dir, part := d.paths()
+	validatorPath := part + ".validator"
+	if d.Segments > 1 {
+		if head, ok := d.probe(ctx); ok {
+			path, err := d.segmented(ctx, head, dir, part, validatorPath)
+			if !errors.Is(err, errFileChanged) {
+				return path, err
+			}
+		}
+	}
+
+	var offset int64
+	validator, _ := ioutil.ReadFile(validatorPath)
+	if info, err := os.Stat(part); err == nil && len(validator) > 0 {
+		offset = info.Size()
+	}
+
+	req := d.Request
+	req.Method = "GET"
+	req.Context = ctx
+	req.BufferBody = false
+	if offset > 0 {
+		req = req.WithHeader("Range", fmt.Sprintf("bytes=%d-", offset)).WithHeader("If-Range", string(validator))
+	}
+	res, err := d.send(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	total := int64(-1)
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case res.StatusCode == http.StatusPartialContent && offset > 0:
+		start, end, size, ok := parseContentRange(res.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return "", &Error{Err: fmt.Errorf("Unexpected Content-Range %q resuming at %d", res.Header.Get("Content-Range"), offset)}
+		}
+		total = size
+		if total < 0 {
+			total = end + 1
+		}
+		flags |= os.O_APPEND
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the previous attempt got the whole file
+		if _, _, size, _ := parseContentRange(res.Header.Get("Content-Range")); size != offset {
+			return "", &Error{Err: fmt.Errorf("Download failed with status %d", res.StatusCode)}
+		}
+		total = offset
+		flags |= os.O_APPEND
+	case res.StatusCode == http.StatusOK:
+		offset = 0
+		total = res.ContentLength
+		flags |= os.O_TRUNC
+	default:
+		return "", &Error{Err: fmt.Errorf("Download failed with status %d", res.StatusCode)}
+	}
+
+	if v := downloadValidator(res.Header); v != "" {
+		if err := ioutil.WriteFile(validatorPath, []byte(v), 0644); err != nil {
+			return "", err
+		}
+	} else {
+		os.Remove(validatorPath)
+	}
+
+	file, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return "", err
+	}
+	hashes, err := d.hashes(part, offset)
+	if err != nil {
+		file.Close()
+		return "", err
+	}
+	written := offset
+	w := io.MultiWriter(append([]io.Writer{file, progressWriter{&written, total, d.Progress}}, hashWriters(hashes)...)...)
+	if res.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		_, err = io.Copy(w, res.Body)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// keep what was written to resume later
+		return "", newError(err)
+	}
+	return d.finish(res, dir, part, validatorPath, written, total, hashes)
+}
+
+// paths returns the directory the file is saved in, if Path is one, and
+// the temporary file it is written to.
+func (d Download) paths() (dir, part string) {
+	if info, err := os.Stat(d.Path); err == nil && info.IsDir() {
+		sum := sha256.Sum256([]byte(d.Request.Uri))
+		return d.Path, filepath.Join(d.Path, ".goreq-"+hex.EncodeToString(sum[:8])+".part")
+	}
+	return "", d.Path + ".part"
+}
+
+func (d Download) send(req Request) (*Response, error) {
+	if d.Client != nil {
+		return d.Client.Do(req)
+	}
+	return req.Do()
+}
+
+// finish checks the size and the digests of part, of which written bytes
+// were downloaded, and renames it to its destination.
+func (d Download) finish(res *Response, dir, part, validatorPath string, written, total int64, hashes map[string]hash.Hash) (string, error) {
+	if total >= 0 && written != total {
+		d.discard(part, validatorPath)
+		return "", &Error{Err: fmt.Errorf("%w: got %d bytes instead of %d", ErrSizeMismatch, written, total)}
+	}
+	for expected, h := range hashes {
+		if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, expected) {
+			d.discard(part, validatorPath)
+			return "", &Error{Err: fmt.Errorf("%w: got %s instead of %s", ErrChecksumMismatch, got, expected)}
+		}
+	}
+
+	dest := d.Path
+	if dir != "" {
+		var err error
+		if dest, err = reserve(dir, downloadName(res)); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Rename(part, dest); err != nil {
+		if dir != "" {
+			os.Remove(dest)
+		}
+		return "", err
+	}
+	os.Remove(validatorPath)
+	return dest, nil
+}
+
+// hashes returns the digests to compute by expected value, fed with the
+// first offset bytes of part already downloaded.
+func (d Download) hashes(part string, offset int64) (map[string]hash.Hash, error) {
+	hashes := make(map[string]hash.Hash)
+	if d.SHA256 != "" {
+		hashes[d.SHA256] = sha256.New()
+	}
+	if d.MD5 != "" {
+		hashes[d.MD5] = md5.New()
+	}
+	if len(hashes) == 0 || offset == 0 {
+		return hashes, nil
+	}
+	file, err := os.Open(part)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	_, err = io.CopyN(io.MultiWriter(hashWriters(hashes)...), file, offset)
+	return hashes, err
+}
+
+func (d Download) discard(paths ...string) {
+	for _, p := range paths {
+		os.Remove(p)
+	}
+}
+
+func hashWriters(hashes map[string]hash.Hash) []io.Writer {
+	var writers []io.Writer
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	return writers
+}
+
+type progressWriter struct {
+	written  *int64
+	total    int64
+	progress func(written, total int64)
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	*w.written += int64(len(p))
+	if w.progress != nil {
+		w.progress(*w.written, w.total)
+	}
+	return len(p), nil
+}
+
+// downloadValidator returns the value to send in If-Range when resuming:
+// a strong ETag, or the Last-Modified date.
+func downloadValidator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+// parseContentRange parses a Content-Range header like "bytes 0-99/1000"
+// or "bytes */1000". size is -1 if the total size is unknown.
+func parseContentRange(value string) (start, end, size int64, ok bool) {
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, 0, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, "bytes "), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, 0, false
+	}
+	size = -1
+	if parts[1] != "*" {
+		var err error
+		if size, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return 0, 0, 0, false
+		}
+	}
+	if parts[0] == "*" {
+		return 0, 0, size, true
+	}
+	bounds := strings.SplitN(parts[0], "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, 0, false
+	}
+	start, err1 := strconv.ParseInt(bounds[0], 10, 64)
+	end, err2 := strconv.ParseInt(bounds[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, 0, false
+	}
+	return start, end, size, true
+}
+
+// reserve creates an empty file in dir named name, or name followed by a
+// number if it is taken, so that no existing file is replaced.
+func reserve(dir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	for i := 0; ; i++ {
+		dest := filepath.Join(dir, name)
+		if i > 0 {
+			dest = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext))
+		}
+		file, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			return dest, file.Close()
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+}
+
+// downloadName returns the name to save the file of res as, from its
+// Content-Disposition header or its URL.
+func downloadName(res *Response) string {
+	if _, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition")); err == nil {
+		// some servers percent-encode the name in the header
+		name := params["filename"]
+		if u, err := url.PathUnescape(name); err == nil {
+			name = u
+		}
+		if name = safeName(name); name != "" {
+			return name
+		}
+	}
+	if res.Request != nil {
+		if name := safeName(path.Base(res.Request.URL.Path)); name != "" {
+			return name
+		}
+	}
+	return "download"
+}
+
+// safeName keeps the last element of name so files can not be written
+// outside of the directory. Hidden names, like .bashrc, are refused.
+func safeName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "/" || strings.HasPrefix(name, ".") {
+		return ""
+	}
+	return name
+}
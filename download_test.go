@@ -0,0 +1,204 @@
+package goreq
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestDownload(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	content := []byte(strings.Repeat("0123456789", 1000))
+	sha := sha256.Sum256(content)
+	sum := md5.Sum(content)
+	contentSHA256, contentMD5 := hex.EncodeToString(sha[:]), hex.EncodeToString(sum[:])
+
+	g.Describe("Download", func() {
+		var ts *httptest.Server
+		var dir string
+		var interrupted, ranges int32
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") != "" {
+					atomic.AddInt32(&ranges, 1)
+				}
+				switch r.URL.Path {
+				case "/interrupted":
+					if atomic.AddInt32(&interrupted, 1) == 1 {
+						w.Header().Set("ETag", `"v1"`)
+						w.Header().Set("Content-Length", "10000")
+						w.Write(content[:4000])
+						return
+					}
+				case "/attachment":
+					w.Header().Set("Content-Disposition", `attachment; filename="../report.txt"`)
+				}
+				w.Header().Set("ETag", `"v1"`)
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "goreq-download")
+			atomic.StoreInt32(&interrupted, 0)
+			atomic.StoreInt32(&ranges, 0)
+		})
+
+		g.AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		g.It("Should download and verify the file", func() {
+			var written, total int64
+			dest := filepath.Join(dir, "file")
+			path, err := Download{
+				Request: Request{Uri: ts.URL + "/file"},
+				Path:    dest,
+				SHA256:  contentSHA256,
+				MD5:     contentMD5,
+				Progress: func(w, t int64) {
+					written, total = w, t
+				},
+			}.Do(context.Background())
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(path).Should(gomega.Equal(dest))
+			data, _ := ioutil.ReadFile(dest)
+			gomega.Expect(data).Should(gomega.Equal(content))
+			gomega.Expect(written).Should(gomega.Equal(int64(len(content))))
+			gomega.Expect(total).Should(gomega.Equal(int64(len(content))))
+			files, _ := ioutil.ReadDir(dir)
+			gomega.Expect(files).Should(gomega.HaveLen(1))
+		})
+
+		g.It("Should resume an interrupted download", func() {
+			dest := filepath.Join(dir, "file")
+			d := Download{Request: Request{Uri: ts.URL + "/interrupted"}, Path: dest, SHA256: contentSHA256}
+			_, err := d.Do(context.Background())
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			info, _ := os.Stat(dest + ".part")
+			gomega.Expect(info.Size()).Should(gomega.Equal(int64(4000)))
+
+			path, err := d.Do(context.Background())
+			gomega.Expect(err).Should(gomega.BeNil())
+			data, _ := ioutil.ReadFile(path)
+			gomega.Expect(data).Should(gomega.Equal(content))
+			gomega.Expect(atomic.LoadInt32(&ranges)).Should(gomega.Equal(int32(1)))
+		})
+
+		g.It("Should start over if the file changed on the server", func() {
+			dest := filepath.Join(dir, "file")
+			ioutil.WriteFile(dest+".part", []byte("stale"), 0644)
+			ioutil.WriteFile(dest+".part.validator", []byte(`"v0"`), 0644)
+			_, err := Download{Request: Request{Uri: ts.URL + "/file"}, Path: dest}.Do(context.Background())
+			gomega.Expect(err).Should(gomega.BeNil())
+			data, _ := ioutil.ReadFile(dest)
+			gomega.Expect(data).Should(gomega.Equal(content))
+			_, err = os.Stat(dest + ".part.validator")
+			gomega.Expect(os.IsNotExist(err)).Should(gomega.BeTrue())
+		})
+
+		g.It("Should remove files that do not match their checksum", func() {
+			dest := filepath.Join(dir, "file")
+			_, err := Download{Request: Request{Uri: ts.URL + "/file"}, Path: dest, MD5: strings.Repeat("0", 32)}.Do(context.Background())
+			gomega.Expect(errors.Is(err, ErrChecksumMismatch)).Should(gomega.BeTrue())
+			files, _ := ioutil.ReadDir(dir)
+			gomega.Expect(files).Should(gomega.BeEmpty())
+		})
+
+		g.It("Should name files saved in a directory after Content-Disposition", func() {
+			path, err := Download{Request: Request{Uri: ts.URL + "/attachment"}, Path: dir}.Do(context.Background())
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(path).Should(gomega.Equal(filepath.Join(dir, "report.txt")))
+
+			path, err = Download{Request: Request{Uri: ts.URL + "/archive.tar"}, Path: dir}.Do(context.Background())
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(path).Should(gomega.Equal(filepath.Join(dir, "archive.tar")))
+		})
+
+		g.It("Should not replace existing files in a directory", func() {
+			ioutil.WriteFile(filepath.Join(dir, "archive.tar"), []byte("mine"), 0644)
+			path, err := Download{Request: Request{Uri: ts.URL + "/archive.tar"}, Path: dir}.Do(context.Background())
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(path).Should(gomega.Equal(filepath.Join(dir, "archive (1).tar")))
+			data, _ := ioutil.ReadFile(filepath.Join(dir, "archive.tar"))
+			gomega.Expect(string(data)).Should(gomega.Equal("mine"))
+			data, _ = ioutil.ReadFile(path)
+			gomega.Expect(data).Should(gomega.Equal(content))
+		})
+
+		g.It("Should fail on error statuses", func() {
+			ts := httptest.NewServer(http.NotFoundHandler())
+			defer ts.Close()
+			_, err := Download{Request: Request{Uri: ts.URL}, Path: filepath.Join(dir, "file")}.Do(context.Background())
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			files, _ := ioutil.ReadDir(dir)
+			gomega.Expect(files).Should(gomega.BeEmpty())
+		})
+	})
+
+	g.Describe("File names", func() {
+		g.It("Should keep downloads in their directory", func() {
+			for _, name := range []string{"..", ".", "/", "a/../..", ".bashrc", "dir/.ssh", ""} {
+				gomega.Expect(safeName(name)).Should(gomega.Equal(""), name)
+			}
+			gomega.Expect(safeName("a/../b.txt")).Should(gomega.Equal("b.txt"))
+			gomega.Expect(safeName(`..\..\evil`)).Should(gomega.Equal("evil"))
+		})
+
+		g.It("Should only unescape the Content-Disposition name", func() {
+			named := func(disposition, path string) string {
+				header := http.Header{}
+				header.Set("Content-Disposition", disposition)
+				u, _ := url.Parse("http://example.com" + path)
+				return downloadName(&Response{Response: &http.Response{Header: header, Request: &http.Request{URL: u}}})
+			}
+			gomega.Expect(named(`attachment; filename="my%20report.pdf"`, "/")).Should(gomega.Equal("my report.pdf"))
+			gomega.Expect(named(`attachment; filename="a%2F..%2Fb.txt"`, "/")).Should(gomega.Equal("b.txt"))
+			gomega.Expect(named(`attachment; filename="%2E%2E"`, "/file.txt")).Should(gomega.Equal("file.txt"))
+			gomega.Expect(named("", "/50%2541.txt")).Should(gomega.Equal("50%41.txt"))
+			gomega.Expect(named("", "/.profile")).Should(gomega.Equal("download"))
+		})
+	})
+
+	g.Describe("Content-Range", func() {
+		g.It("Should parse ranges and sizes", func() {
+			start, end, size, ok := parseContentRange("bytes 10-19/100")
+			gomega.Expect(ok).Should(gomega.BeTrue())
+			gomega.Expect([]int64{start, end, size}).Should(gomega.Equal([]int64{10, 19, 100}))
+
+			_, _, size, ok = parseContentRange("bytes */100")
+			gomega.Expect(ok).Should(gomega.BeTrue())
+			gomega.Expect(size).Should(gomega.Equal(int64(100)))
+
+			_, _, size, ok = parseContentRange("bytes 0-9/*")
+			gomega.Expect(ok).Should(gomega.BeTrue())
+			gomega.Expect(size).Should(gomega.Equal(int64(-1)))
+
+			_, _, _, ok = parseContentRange("items 0-9/10")
+			gomega.Expect(ok).Should(gomega.BeFalse())
+		})
+	})
+}
@@ -5,4 +5,5 @@ go 1.16
 require (
 	github.com/franela/goblin v0.0.0-20211003143422-0a4f594942bf
 	github.com/onsi/gomega v1.20.0
+	golang.org/x/net v0.11.0
 )
@@ -5,7 +5,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"compress/zlib"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -25,20 +25,24 @@ type itimeout interface {
 	Timeout() bool
 }
 type Request struct {
-	headers             []headerTuple
-	cookies             []*http.Cookie
-	Method              string
-	Uri                 string
-	Body                interface{}
-	QueryString         interface{}
-	Timeout             time.Duration
-	ContentType         string
-	Accept              string
-	Host                string
-	UserAgent           string
-	Insecure            bool
-	MaxRedirects        int
-	RedirectHeaders     bool
+	headers         []headerTuple
+	cookies         []*http.Cookie
+	Method          string
+	Uri             string
+	Body            interface{}
+	QueryString     interface{}
+	Timeout         time.Duration
+	ContentType     string
+	Accept          string
+	Host            string
+	UserAgent       string
+	Insecure        bool
+	MaxRedirects    int
+	RedirectHeaders bool
+	// RedirectKeepMethod keeps the method and body of the request when
+	// following 301 and 302 redirects instead of switching to GET.
+	RedirectKeepMethod  bool
+	RedirectPolicy      RedirectPolicy
 	Proxy               string
 	proxyConnectHeaders []headerTuple
 	Compression         *compression
@@ -47,6 +51,27 @@ type Request struct {
 	CookieJar           http.CookieJar
 	ShowDebug           bool
 	OnBeforeRequest     func(goreq *Request, httpreq *http.Request)
+	// MaxBodySize limits how many bytes of the response body are buffered
+	// by Body.Bytes. Zero means no limit.
+	MaxBodySize int64
+	// BufferBody makes Do read the whole response body and close the
+	// connection before returning.
+	BufferBody bool
+	// Context, if set, is attached to the http.Request so the request is
+	// cancelled when it is done.
+	Context        context.Context
+	RateLimiter    *RateLimiter
+	CircuitBreaker *CircuitBreaker
+	Cache          CacheStore
+	Coalescer      *Coalescer
+	Hedging        *Hedging
+	// TLS, if set, holds TLS settings used instead of the ones of the
+	// package DefaultTransport.
+	TLS *TLSConfig
+	// PhaseTimeouts limits the phases of the exchanges of the request.
+	PhaseTimeouts PhaseTimeouts
+	client        *Client
+	proxyPool     *ProxyPool
 }
 
 type compression struct {
@@ -59,11 +84,27 @@ type Response struct {
 	*http.Response
 	Uri  string
 	Body *Body
-	req  *http.Request
+	// History holds the redirects followed to get this response, oldest
+	// first.
+	History []RedirectHop
+	// CacheStatus tells whether the response was served from the Cache.
+	CacheStatus CacheStatus
+	// HedgeAttempt is the copy of the request, starting at 1, whose
+	// response was used when Hedging is enabled.
+	HedgeAttempt int
+	// Proxy is the proxy the request was sent through, if any, with its
+	// password redacted.
+	Proxy     string
+	req       *http.Request
+	transport http.RoundTripper
 }
 
 func (r Response) CancelRequest() {
-	cancelRequest(DefaultTransport, r.req)
+	transport := r.transport
+	if transport == nil {
+		transport = DefaultTransport
+	}
+	cancelRequest(transport, r.req)
 
 }
 
@@ -81,10 +122,21 @@ type headerTuple struct {
 type Body struct {
 	reader           io.ReadCloser
 	compressedReader io.ReadCloser
+	limit            int64
+	buffered         bool
+	content          []byte
+	cache            *bytes.Reader
 }
 
+var ErrBodyTooLarge = errors.New("Response body exceeds MaxBodySize")
+
+// ErrBodyNotReplayable is returned when following a 307 or 308 redirect
+// requires sending the request body again but it was a one-shot io.Reader.
+var ErrBodyNotReplayable = errors.New("Error redirecting. Request body can not be replayed")
+
 type Error struct {
 	timeout bool
+	phase   TimeoutPhase
 	Err     error
 }
 
@@ -100,7 +152,14 @@ func (e *Error) Error() string {
 	return e.Err.Error()
 }
 
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 func (b *Body) Read(p []byte) (int, error) {
+	if b.buffered {
+		return b.cache.Read(p)
+	}
 	if b.compressedReader != nil {
 		return b.compressedReader.Read(p)
 	}
@@ -108,6 +167,9 @@ func (b *Body) Read(p []byte) (int, error) {
 }
 
 func (b *Body) Close() error {
+	if b.buffered {
+		return nil
+	}
 	err := b.reader.Close()
 	if b.compressedReader != nil {
 		return b.compressedReader.Close()
@@ -115,12 +177,56 @@ func (b *Body) Close() error {
 	return err
 }
 
+// Bytes reads the (decompressed) body the first time it is called, closes
+// the underlying connection and caches the content. Later calls return the
+// cached content, and Read starts serving it from the beginning.
+// If the request had a MaxBodySize and the body is bigger, ErrBodyTooLarge
+// is returned.
+func (b *Body) Bytes() ([]byte, error) {
+	if b.buffered {
+		return b.content, nil
+	}
+	var stream io.Reader = b.reader
+	if b.compressedReader != nil {
+		stream = b.compressedReader
+	}
+	if b.limit > 0 {
+		stream = io.LimitReader(stream, b.limit+1)
+	}
+	content, err := ioutil.ReadAll(stream)
+	b.Close()
+	if err != nil {
+		return nil, err
+	}
+	if b.limit > 0 && int64(len(content)) > b.limit {
+		return nil, ErrBodyTooLarge
+	}
+	b.buffered = true
+	b.content = content
+	b.cache = bytes.NewReader(content)
+	return content, nil
+}
+
+// Rewind buffers the body if needed and moves the read position back to
+// its beginning, so it can be handed to another decoder.
+func (b *Body) Rewind() error {
+	if _, err := b.Bytes(); err != nil {
+		return err
+	}
+	b.cache.Seek(0, io.SeekStart)
+	return nil
+}
+
 func (b *Body) FromJsonTo(o interface{}) error {
-	return json.NewDecoder(b).Decode(o)
+	body, err := b.Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, o)
 }
 
 func (b *Body) ToString() (string, error) {
-	body, err := ioutil.ReadAll(b)
+	body, err := b.Bytes()
 	if err != nil {
 		return "", err
 	}
@@ -245,9 +351,6 @@ var DefaultDialer = &net.Dialer{Timeout: 1000 * time.Millisecond}
 var DefaultTransport http.RoundTripper = &http.Transport{Dial: DefaultDialer.Dial, Proxy: http.ProxyFromEnvironment}
 var DefaultClient = &http.Client{Transport: DefaultTransport}
 
-var proxyTransport http.RoundTripper
-var proxyClient *http.Client
-
 func SetConnectTimeout(duration time.Duration) {
 	DefaultDialer.Timeout = duration
 }
@@ -290,8 +393,10 @@ func (r Request) Do() (*Response, error) {
 	var transport = DefaultTransport
 	var resUri string
 	var redirectFailed bool
+	var history []RedirectHop
 
 	r.Method = valueOrDefault(r.Method, "GET")
+	r.pickProxy()
 
 	// use a client with a cookie jar if necessary. We create a new client not
 	// to modify the default one.
@@ -302,35 +407,21 @@ func (r Request) Do() (*Response, error) {
 		}
 	}
 
-	if r.Proxy != "" {
-		proxyUrl, err := url.Parse(r.Proxy)
-		if err != nil {
-			// proxy address is in a wrong format
-			return nil, &Error{Err: err}
-		}
-		proxyHeader := make(http.Header)
-		if r.proxyConnectHeaders != nil {
-			for _, header := range r.proxyConnectHeaders {
-				proxyHeader[header.name] = []string{header.value}
-			}
-		}
-
-		//If jar is specified new client needs to be built
-		if proxyTransport == nil || client.Jar != nil {
-			proxyTransport = &http.Transport{
-				Dial:               DefaultDialer.Dial,
-				Proxy:              http.ProxyURL(proxyUrl),
-				ProxyConnectHeader: proxyHeader,
-			}
-			proxyClient = &http.Client{Transport: proxyTransport, Jar: client.Jar}
-		} else if proxyTransport, ok := proxyTransport.(*http.Transport); ok {
-			proxyTransport.Proxy = http.ProxyURL(proxyUrl)
-			proxyTransport.ProxyConnectHeader = proxyHeader
-		}
-		transport = proxyTransport
-		client = proxyClient
+	custom, err := r.transport()
+	if err != nil {
+		return nil, &Error{Err: err}
+	}
+	if custom != nil {
+		transport = custom
+		client = &http.Client{Transport: custom, Jar: r.CookieJar}
 	}
 
+	// work on a copy so per request settings don't leak into shared clients
+	httpClient := *client
+	client = &httpClient
+	ex := &exchange{}
+	client.Transport = r.wrapTransport(client.Transport, ex)
+
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 
 		if len(via) > r.MaxRedirects {
@@ -338,30 +429,20 @@ func (r Request) Do() (*Response, error) {
 			return errors.New("Error redirecting. MaxRedirects reached")
 		}
 
-		resUri = req.URL.String()
-
-		//By default Golang will not redirect request headers
-		// https://code.google.com/p/go/issues/detail?id=4800&q=request%20header
-		if r.RedirectHeaders {
-			for key, val := range via[0].Header {
-				req.Header[key] = val
+		if err := r.prepareRedirect(req, via); err != nil {
+			return err
+		}
+		if r.RedirectPolicy != nil {
+			if err := r.RedirectPolicy(req, via); err != nil {
+				return err
 			}
 		}
-		return nil
-	}
 
-	if transport, ok := transport.(*http.Transport); ok {
-		if r.Insecure {
-			if transport.TLSClientConfig != nil {
-				transport.TLSClientConfig.InsecureSkipVerify = true
-			} else {
-				transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-			}
-		} else if transport.TLSClientConfig != nil {
-			// the default TLS client (when transport.TLSClientConfig==nil) is
-			// already set to verify, so do nothing in that case
-			transport.TLSClientConfig.InsecureSkipVerify = false
+		resUri = req.URL.String()
+		if res := req.Response; res != nil {
+			history = append(history, RedirectHop{Uri: via[len(via)-1].URL.String(), StatusCode: res.StatusCode, Header: res.Header})
 		}
+		return nil
 	}
 
 	req, err := r.NewRequest()
@@ -371,7 +452,19 @@ func (r Request) Do() (*Response, error) {
 		return nil, &Error{Err: err}
 	}
 
-	timeout := false
+	// files and other closable bodies are closed once every redirect that
+	// may replay them is done, rather than by the transport after the first
+	// send
+	if body, ok := req.Body.(io.ReadSeekCloser); ok && req.GetBody != nil {
+		req.Body = ioutil.NopCloser(body)
+		defer body.Close()
+	}
+	// bodies rewound by seeking can only be read by one copy at a time
+	if rs, ok := r.Body.(io.ReadSeeker); ok && r.Compression == nil && req.GetBody != nil {
+		_, readerAt := rs.(io.ReaderAt)
+		ex.sharedBody = !readerAt
+	}
+
 	if r.Timeout > 0 {
 		client.Timeout = r.Timeout
 	}
@@ -389,25 +482,19 @@ func (r Request) Do() (*Response, error) {
 	}
 	res, err := client.Do(req)
 
+	newResponse := func(body *Body) *Response {
+		return &Response{Response: res, Uri: resUri, Body: body, History: history, CacheStatus: ex.cacheStatus, HedgeAttempt: ex.hedgeAttempt, Proxy: redactProxy(r.Proxy), req: req, transport: transport}
+	}
+
 	if err != nil {
-		if !timeout {
-			if t, ok := err.(itimeout); ok {
-				timeout = t.Timeout()
-			}
-			if ue, ok := err.(*url.Error); ok {
-				if t, ok := ue.Err.(itimeout); ok {
-					timeout = t.Timeout()
-				}
-			}
-		}
 
 		var response *Response
 		//If redirect fails we still want to return response data
 		if redirectFailed {
 			if res != nil {
-				response = &Response{res, resUri, &Body{reader: res.Body}, req}
+				response = newResponse(&Body{reader: res.Body, limit: r.MaxBodySize})
 			} else {
-				response = &Response{res, resUri, nil, req}
+				response = newResponse(nil)
 			}
 		}
 
@@ -416,18 +503,39 @@ func (r Request) Do() (*Response, error) {
 			return response, nil
 		}
 
-		return response, &Error{timeout: timeout, Err: err}
+		return response, newError(err)
+	}
+
+	if r.MaxRedirects > 0 && needsBodyReplay(req, res) {
+		return newResponse(&Body{reader: res.Body, limit: r.MaxBodySize}), &Error{Err: ErrBodyNotReplayable}
 	}
 
+	body := &Body{reader: res.Body, limit: r.MaxBodySize}
 	if r.Compression != nil && strings.Contains(res.Header.Get("Content-Encoding"), r.Compression.ContentEncoding) {
 		compressedReader, err := r.Compression.reader(res.Body)
 		if err != nil {
 			return nil, &Error{Err: err}
 		}
-		return &Response{res, resUri, &Body{reader: res.Body, compressedReader: compressedReader}, req}, nil
+		body.compressedReader = compressedReader
 	}
 
-	return &Response{res, resUri, &Body{reader: res.Body}, req}, nil
+	response := newResponse(body)
+	if r.BufferBody {
+		if _, err := body.Bytes(); err != nil {
+			return response, newError(err)
+		}
+	}
+	return response, nil
+}
+
+func isTimeout(err error) bool {
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err
+	}
+	if t, ok := err.(itimeout); ok {
+		return t.Timeout()
+	}
+	return false
 }
 
 func (r Request) addHeaders(headersMap http.Header) {
@@ -443,6 +551,9 @@ func (r Request) addHeaders(headersMap http.Header) {
 }
 
 func (r Request) NewRequest() (*http.Request, error) {
+	if _, uri, ok := splitUnixURI(r.Uri); ok {
+		r.Uri = uri
+	}
 
 	b, e := prepareRequestBody(r.Body)
 	if e != nil {
@@ -480,6 +591,16 @@ func (r Request) NewRequest() (*http.Request, error) {
 	if err != nil {
 		return nil, err
 	}
+	if r.Context != nil {
+		req = req.WithContext(r.Context)
+	}
+	// strings, []byte, JSON and compressed bodies are already replayable
+	// through http.NewRequest. Other io.Readers are only if they can seek.
+	if rs, ok := bodyReader.(io.ReadSeeker); ok && req.GetBody == nil {
+		if err := replayableBody(req, rs); err != nil {
+			return nil, &Error{Err: err}
+		}
+	}
 	// add headers to the request
 	req.Host = r.Host
 
@@ -505,6 +626,64 @@ func (r Request) NewRequest() (*http.Request, error) {
 	return req, nil
 }
 
+// replayableBody sets GetBody on a request whose body is an io.ReadSeeker
+// so it can be sent again on redirects and retries, by rewinding it to
+// where it started, or with independent section readers if it is also an
+// io.ReaderAt. Readers that fail to seek (like pipes) are left untouched
+// and remain one-shot. Bodies that are io.Closers, like files,
+// are still closed once sent, so Request.Do keeps them open until every
+// redirect is done instead.
+func replayableBody(req *http.Request, rs io.ReadSeeker) error {
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil
+	}
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	req.ContentLength = end - start
+	if req.ContentLength == 0 {
+		if closer, ok := rs.(io.Closer); ok {
+			closer.Close()
+		}
+		req.Body = http.NoBody
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return nil
+	}
+	req.Body = ioutil.NopCloser(rs)
+	if rc, ok := rs.(io.ReadCloser); ok {
+		// closed once sent, like any other body
+		req.Body = rc
+	}
+	if ra, ok := rs.(io.ReaderAt); ok {
+		// independent readers, so copies can be read at the same time
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(io.NewSectionReader(ra, start, req.ContentLength)), nil
+		}
+		return nil
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		if _, err := rs.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(rs), nil
+	}
+	return nil
+}
+
+// needsBodyReplay reports whether res is a 307/308 redirect that the client
+// did not follow because the request body could not be sent again.
+func needsBodyReplay(req *http.Request, res *http.Response) bool {
+	if res.StatusCode != http.StatusTemporaryRedirect && res.StatusCode != http.StatusPermanentRedirect {
+		return false
+	}
+	return res.Header.Get("Location") != "" && req.GetBody == nil && req.Body != nil && req.Body != http.NoBody
+}
+
 // Return value if nonempty, def otherwise.
 func valueOrDefault(value, def string) string {
 	if value != "" {
@@ -3,6 +3,7 @@ package goreq
 import (
 	"compress/gzip"
 	"compress/zlib"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"github.com/franela/goblin"
@@ -10,11 +11,14 @@ import (
 	"io"
 	"io/ioutil"
 	"math"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -667,6 +671,44 @@ func TestRequest(t *testing.T) {
 
 					gomega.Expect(res.Response).ShouldNot(gomega.BeNil())
 				})
+
+				g.It("Should allow reading the body several times", func() {
+					res, _ := Request{Method: "POST", Uri: ts.URL, Body: `{"foo": "bar"}`}.Do()
+
+					str, _ := res.Body.ToString()
+					gomega.Expect(str).Should(gomega.Equal(`{"foo": "bar"}`))
+
+					var foobar map[string]string
+					err := res.Body.FromJsonTo(&foobar)
+					gomega.Expect(err).Should(gomega.BeNil())
+					gomega.Expect(foobar).Should(gomega.Equal(map[string]string{"foo": "bar"}))
+
+					gomega.Expect(res.Body.Rewind()).Should(gomega.BeNil())
+					body, _ := ioutil.ReadAll(res.Body)
+					gomega.Expect(string(body)).Should(gomega.Equal(`{"foo": "bar"}`))
+				})
+
+				g.It("Should buffer the body before returning if BufferBody is set", func() {
+					res, err := Request{Method: "POST", Uri: ts.URL, Body: "foo bar", BufferBody: true}.Do()
+					gomega.Expect(err).Should(gomega.BeNil())
+
+					_, e := ioutil.ReadAll(res.Body.reader)
+					//error because the connection was already closed
+					gomega.Expect(e).ShouldNot(gomega.BeNil())
+
+					str, _ := res.Body.ToString()
+					gomega.Expect(str).Should(gomega.Equal("foo bar"))
+				})
+
+				g.It("Should fail when the body exceeds MaxBodySize", func() {
+					res, _ := Request{Method: "POST", Uri: ts.URL, Body: "foo bar", MaxBodySize: 3}.Do()
+
+					_, err := res.Body.Bytes()
+					gomega.Expect(err).Should(gomega.Equal(ErrBodyTooLarge))
+
+					_, err = Request{Method: "POST", Uri: ts.URL, Body: "foo bar", MaxBodySize: 3, BufferBody: true}.Do()
+					gomega.Expect(err.(*Error).Err).Should(gomega.Equal(ErrBodyTooLarge))
+				})
 			})
 			g.Describe("Redirects", func() {
 				g.It("Should not follow by default", func() {
@@ -738,6 +780,79 @@ func TestRequest(t *testing.T) {
 					}.Do()
 					gomega.Expect(res.Uri).Should(gomega.Equal(ts.URL + "/destination"))
 				})
+
+				g.Describe("Request bodies", func() {
+					var rts *httptest.Server
+
+					g.Before(func() {
+						rts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+							if r.URL.Path == "/307" {
+								http.Redirect(w, r, "/echo", 307)
+								return
+							}
+							if r.URL.Path == "/308" {
+								http.Redirect(w, r, "/echo", 308)
+								return
+							}
+							w.WriteHeader(200)
+							io.Copy(w, r.Body)
+						}))
+					})
+
+					g.After(func() {
+						rts.Close()
+					})
+
+					g.It("Should replay string and JSON bodies on 307 and 308", func() {
+						res, err := Request{Method: "POST", Uri: rts.URL + "/307", Body: "foo bar", MaxRedirects: 1}.Do()
+						gomega.Expect(err).Should(gomega.BeNil())
+						str, _ := res.Body.ToString()
+						gomega.Expect(str).Should(gomega.Equal("foo bar"))
+
+						res, err = Request{Method: "PUT", Uri: rts.URL + "/308", Body: map[string]string{"foo": "bar"}, MaxRedirects: 1, Compression: Gzip()}.Do()
+						gomega.Expect(err).Should(gomega.BeNil())
+						gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+					})
+
+					g.It("Should replay io.ReadSeeker bodies", func() {
+						body := strings.NewReader("--foo bar")
+						body.Seek(2, io.SeekStart)
+						req, _ := Request{Method: "POST", Uri: rts.URL + "/307", Body: struct{ io.ReadSeeker }{body}}.NewRequest()
+						gomega.Expect(req.GetBody).ShouldNot(gomega.BeNil())
+						gomega.Expect(req.ContentLength).Should(gomega.Equal(int64(7)))
+
+						res, err := Request{Method: "POST", Uri: rts.URL + "/307", Body: struct{ io.ReadSeeker }{body}, MaxRedirects: 1}.Do()
+						gomega.Expect(err).Should(gomega.BeNil())
+						str, _ := res.Body.ToString()
+						gomega.Expect(str).Should(gomega.Equal("foo bar"))
+					})
+
+					g.It("Should replay file bodies and close them once done", func() {
+						f, _ := ioutil.TempFile("", "goreq-body")
+						defer os.Remove(f.Name())
+						f.WriteString("foo bar")
+						f.Seek(0, io.SeekStart)
+						res, err := Request{Method: "POST", Uri: rts.URL + "/307", Body: f, MaxRedirects: 1}.Do()
+						gomega.Expect(err).Should(gomega.BeNil())
+						str, _ := res.Body.ToString()
+						gomega.Expect(str).Should(gomega.Equal("foo bar"))
+						_, err = f.Stat()
+						gomega.Expect(err).ShouldNot(gomega.BeNil())
+
+						f, _ = os.Open(f.Name())
+						req, _ := Request{Method: "POST", Uri: rts.URL, Body: f}.NewRequest()
+						gomega.Expect(req.Body).Should(gomega.Equal(f))
+						f.Close()
+					})
+
+					g.It("Should fail when a one-shot body has to be replayed", func() {
+						body := struct{ io.Reader }{strings.NewReader("foo bar")}
+						res, err := Request{Method: "POST", Uri: rts.URL + "/307", Body: body, MaxRedirects: 1}.Do()
+						gomega.Expect(err).ShouldNot(gomega.BeNil())
+						gomega.Expect(err.(*Error).Err).Should(gomega.Equal(ErrBodyNotReplayable))
+						gomega.Expect(res.StatusCode).Should(gomega.Equal(307))
+					})
+				})
 			})
 		})
 
@@ -896,7 +1011,7 @@ func TestRequest(t *testing.T) {
 				req := Request{Uri: ts.URL, Host: "foobar.com"}
 				req.Do()
 			})
-			g.It("Should change transport TLS config if Request.Insecure is set", func() {
+			g.It("Should skip verification without changing the transport TLS config if Request.Insecure is set", func() {
 				ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 					w.WriteHeader(200)
 				}))
@@ -909,8 +1024,12 @@ func TestRequest(t *testing.T) {
 				}
 				res, _ := req.Do()
 
-				gomega.Expect(DefaultClient.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify).Should(gomega.Equal(true))
+				config := DefaultClient.Transport.(*http.Transport).TLSClientConfig
+				gomega.Expect(config == nil || !config.InsecureSkipVerify).Should(gomega.BeTrue())
 				gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+
+				_, err := Request{Uri: ts.URL}.Do()
+				gomega.Expect(err).ShouldNot(gomega.BeNil())
 			})
 			g.It("Should work if a different transport is specified", func() {
 				ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -918,17 +1037,23 @@ func TestRequest(t *testing.T) {
 				}))
 				defer ts.Close()
 				var currentTransport = DefaultTransport
-				DefaultTransport = &http.Transport{Dial: DefaultDialer.Dial}
+				var dials int32
+				DefaultTransport = &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					atomic.AddInt32(&dials, 1)
+					return DefaultDialer.DialContext(ctx, network, addr)
+				}}
 
 				req := Request{
 					Insecure: true,
 					Uri:      ts.URL,
 					Host:     "foobar.com",
 				}
-				res, _ := req.Do()
+				res, err := req.Do()
 
-				gomega.Expect(DefaultClient.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify).Should(gomega.Equal(true))
+				gomega.Expect(err).Should(gomega.BeNil())
+				gomega.Expect(DefaultTransport.(*http.Transport).TLSClientConfig).Should(gomega.BeNil())
 				gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+				gomega.Expect(atomic.LoadInt32(&dials)).Should(gomega.Equal(int32(1)))
 
 				DefaultTransport = currentTransport
 
@@ -1050,6 +1175,31 @@ func TestRequest(t *testing.T) {
 				gomega.Expect(res.Header.Get("x-forwarded-for")).Should(gomega.Equal("test"))
 				gomega.Expect(lastReq).ShouldNot(gomega.BeNil())
 				gomega.Expect(lastReq.Header.Get("Proxy-Authorization")).Should(gomega.Equal("Basic dXNlcjpwYXNz"))
+				gomega.Expect(res.Proxy).Should(gomega.Equal(strings.Replace(ts.URL, "http://", "http://user:xxxxx@", -1)))
+			})
+
+			g.It("Should send concurrent requests through their own proxies", func() {
+				var hits [2]int32
+				var proxies [2]*httptest.Server
+				for i := range proxies {
+					i := i
+					proxies[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						atomic.AddInt32(&hits[i], 1)
+					}))
+					defer proxies[i].Close()
+				}
+				done := make(chan error)
+				for n := 0; n < 20; n++ {
+					go func(n int) {
+						_, err := Request{Uri: "http://www.google.com", Proxy: proxies[n%2].URL}.Do()
+						done <- err
+					}(n)
+				}
+				for n := 0; n < 20; n++ {
+					gomega.Expect(<-done).Should(gomega.BeNil())
+				}
+				gomega.Expect(atomic.LoadInt32(&hits[0])).Should(gomega.Equal(int32(10)))
+				gomega.Expect(atomic.LoadInt32(&hits[1])).Should(gomega.Equal(int32(10)))
 			})
 
 			g.It("Should propagate cookies", func() {
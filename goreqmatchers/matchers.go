@@ -0,0 +1,228 @@
+// Package goreqmatchers provides gomega matchers that operate on
+// *goreq.Response values, for use in integration tests.
+//
+//	res, err := goreq.Request{Uri: ts.URL + "/items"}.Do()
+//	Expect(err).ShouldNot(HaveOccurred())
+//	Expect(res).Should(goreqmatchers.HaveStatus(200))
+//	Expect(res).Should(goreqmatchers.HaveHeader("Content-Type", ContainSubstring("json")))
+//	Expect(res).Should(goreqmatchers.HaveJSONPath("items[0].id", 42))
+//
+// The response body is read only once, the first time a matcher needs it,
+// and is kept around so that several matchers can inspect the same response.
+package goreqmatchers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+	"github.com/yunmoon/goreq"
+)
+
+// BodyOf returns the content of the response body. It relies on
+// goreq.Body.Bytes, so the body is read once and cached.
+func BodyOf(res *goreq.Response) ([]byte, error) {
+	if res == nil || res.Body == nil {
+		return nil, fmt.Errorf("response has no body")
+	}
+	return res.Body.Bytes()
+}
+
+// HaveStatus succeeds if the response status code matches expected, which
+// can be an int or another matcher.
+func HaveStatus(expected interface{}) types.GomegaMatcher {
+	return &responseMatcher{
+		description: "to have status",
+		expected:    matcherOrEqual(expected),
+		extract: func(res *goreq.Response) (interface{}, error) {
+			return res.StatusCode, nil
+		},
+	}
+}
+
+// HaveHeader succeeds if the response has the given header. If expected is
+// given, the (first) header value must also match it; expected can be a
+// string or another matcher.
+func HaveHeader(name string, expected ...interface{}) types.GomegaMatcher {
+	m := &responseMatcher{
+		description: fmt.Sprintf("to have header %q", name),
+		extract: func(res *goreq.Response) (interface{}, error) {
+			if _, ok := res.Header[http.CanonicalHeaderKey(name)]; !ok {
+				return nil, errMissing
+			}
+			return res.Header.Get(name), nil
+		},
+	}
+	if len(expected) > 0 {
+		m.expected = matcherOrEqual(expected[0])
+	}
+	return m
+}
+
+// HaveBody succeeds if the response body, as a string, matches expected,
+// which can be a string or another matcher.
+func HaveBody(expected interface{}) types.GomegaMatcher {
+	return &responseMatcher{
+		description: "to have body",
+		expected:    matcherOrEqual(expected),
+		extract: func(res *goreq.Response) (interface{}, error) {
+			b, err := BodyOf(res)
+			return string(b), err
+		},
+	}
+}
+
+// HaveJSONBody succeeds if the response body matches expected. If expected
+// is not a matcher it is compared using gomega.MatchJSON.
+func HaveJSONBody(expected interface{}) types.GomegaMatcher {
+	m, ok := expected.(types.GomegaMatcher)
+	if !ok {
+		m = gomega.MatchJSON(expected)
+	}
+	return &responseMatcher{
+		description: "to have JSON body",
+		expected:    m,
+		extract: func(res *goreq.Response) (interface{}, error) {
+			b, err := BodyOf(res)
+			return string(b), err
+		},
+	}
+}
+
+// HaveJSONPath decodes the response body as JSON and succeeds if the value
+// found at path matches expected. Paths are dot separated keys with
+// optional array indexes, like "items[0].id". Numbers are compared
+// numerically, so HaveJSONPath("id", 42) matches {"id": 42}.
+func HaveJSONPath(path string, expected interface{}) types.GomegaMatcher {
+	return &responseMatcher{
+		description: fmt.Sprintf("to have JSON path %q", path),
+		expected:    matcherOrEqual(expected),
+		extract: func(res *goreq.Response) (interface{}, error) {
+			b, err := BodyOf(res)
+			if err != nil {
+				return nil, err
+			}
+			var doc interface{}
+			if err := json.Unmarshal(b, &doc); err != nil {
+				return nil, err
+			}
+			return lookupJSONPath(doc, path)
+		},
+	}
+}
+
+var errMissing = fmt.Errorf("not present")
+
+type responseMatcher struct {
+	description string
+	expected    types.GomegaMatcher
+	extract     func(res *goreq.Response) (interface{}, error)
+
+	value   interface{}
+	missing bool
+}
+
+func (m *responseMatcher) Match(actual interface{}) (bool, error) {
+	res, err := toResponse(actual)
+	if err != nil {
+		return false, err
+	}
+	m.value, err = m.extract(res)
+	if err == errMissing {
+		m.missing = true
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if m.expected == nil {
+		return true, nil
+	}
+	return m.expected.Match(m.value)
+}
+
+func (m *responseMatcher) FailureMessage(actual interface{}) string {
+	if m.missing || m.expected == nil {
+		return format.Message(actual, m.description)
+	}
+	return fmt.Sprintf("Expected response %s\n%s", m.description, m.expected.FailureMessage(m.value))
+}
+
+func (m *responseMatcher) NegatedFailureMessage(actual interface{}) string {
+	if m.expected == nil {
+		return format.Message(actual, "not "+m.description)
+	}
+	return fmt.Sprintf("Expected response not %s\n%s", m.description, m.expected.NegatedFailureMessage(m.value))
+}
+
+func toResponse(actual interface{}) (*goreq.Response, error) {
+	switch res := actual.(type) {
+	case *goreq.Response:
+		if res == nil {
+			return nil, fmt.Errorf("expected a *goreq.Response, got nil")
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("expected a *goreq.Response, got %s", format.Object(actual, 1))
+	}
+}
+
+func matcherOrEqual(expected interface{}) types.GomegaMatcher {
+	if m, ok := expected.(types.GomegaMatcher); ok {
+		return m
+	}
+	switch reflect.ValueOf(expected).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return gomega.BeNumerically("==", expected)
+	}
+	return gomega.Equal(expected)
+}
+
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	cur := doc
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indexes []int
+		if i := strings.Index(part, "["); i >= 0 {
+			key = part[:i]
+			rest := part[i:]
+			for rest != "" {
+				end := strings.Index(rest, "]")
+				if rest[0] != '[' || end < 0 {
+					return nil, fmt.Errorf("invalid JSON path %q", path)
+				}
+				n, err := strconv.Atoi(rest[1:end])
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSON path %q", path)
+				}
+				indexes = append(indexes, n)
+				rest = rest[end+1:]
+			}
+		}
+		if key != "" {
+			obj, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, errMissing
+			}
+			if cur, ok = obj[key]; !ok {
+				return nil, errMissing
+			}
+		}
+		for _, n := range indexes {
+			arr, ok := cur.([]interface{})
+			if !ok || n < 0 || n >= len(arr) {
+				return nil, errMissing
+			}
+			cur = arr[n]
+		}
+	}
+	return cur, nil
+}
@@ -0,0 +1,60 @@
+package goreqmatchers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+	"github.com/yunmoon/goreq"
+)
+
+func TestMatchers(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Response matchers", func() {
+		var ts *httptest.Server
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(200)
+				fmt.Fprint(w, `{"items":[{"id":42,"name":"foo"},{"id":43,"name":"bar"}]}`)
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should match status and headers", func() {
+			res, err := goreq.Request{Uri: ts.URL}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+
+			gomega.Expect(res).Should(HaveStatus(200))
+			gomega.Expect(res).ShouldNot(HaveStatus(gomega.BeNumerically(">=", 400)))
+			gomega.Expect(res).Should(HaveHeader("Content-Type", gomega.ContainSubstring("json")))
+			gomega.Expect(res).Should(HaveHeader("Content-Type"))
+			gomega.Expect(res).ShouldNot(HaveHeader("X-Missing"))
+		})
+
+		g.It("Should let several matchers inspect the body", func() {
+			res, err := goreq.Request{Uri: ts.URL}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+
+			gomega.Expect(res).Should(HaveJSONBody(gomega.MatchJSON(`{"items":[{"id":42,"name":"foo"},{"name":"bar","id":43}]}`)))
+			gomega.Expect(res).Should(HaveJSONPath("items[0].id", 42))
+			gomega.Expect(res).Should(HaveJSONPath("items[1].name", "bar"))
+			gomega.Expect(res).ShouldNot(HaveJSONPath("items[2].id", 44))
+			gomega.Expect(res).Should(HaveBody(gomega.ContainSubstring(`"id":42`)))
+		})
+
+		g.It("Should fail when the actual value is not a response", func() {
+			_, err := HaveStatus(200).Match(200)
+			gomega.Expect(err).Should(gomega.HaveOccurred())
+		})
+	})
+}
@@ -0,0 +1,189 @@
+package goreq
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Hedging sends extra copies of a request that has not received response
+// headers after some delay, uses whichever response arrives first and
+// cancels the others. Only idempotent methods are hedged unless
+// NonIdempotent is set. Requests whose body can not be replayed, or is an
+// io.ReadSeeker that is not also an io.ReaderAt and so can not be read by
+// several copies at once, are never hedged.
+type Hedging struct {
+	// Delay is how long to wait for headers before sending another copy.
+	Delay time.Duration
+	// Percentile, if set (for example 0.95), makes the delay the given
+	// percentile of the latencies observed so far. Delay is used until
+	// enough requests have been observed.
+	Percentile float64
+	// MaxAttempts is the total number of copies that can be sent,
+	// including the first one. It defaults to 2.
+	MaxAttempts   int
+	NonIdempotent bool
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+const hedgingSamples = 100
+
+type hedgeResult struct {
+	attempt int
+	res     *http.Response
+	err     error
+	cancel  context.CancelFunc
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+func (h *Hedging) maxAttempts() int {
+	if h.MaxAttempts > 0 {
+		return h.MaxAttempts
+	}
+	return 2
+}
+
+// delay returns how long to wait before sending the next copy.
+func (h *Hedging) delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.Percentile <= 0 || len(h.latencies) < 10 {
+		return h.Delay
+	}
+	sorted := append([]time.Duration(nil), h.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(h.Percentile * float64(len(sorted)))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func (h *Hedging) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgingSamples {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgingSamples
+}
+
+func (h *Hedging) roundTrip(transport http.RoundTripper, req *http.Request, ex *exchange) (*http.Response, error) {
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if (!isIdempotent(req.Method) && !h.NonIdempotent) || (hasBody && (req.GetBody == nil || ex.sharedBody)) {
+		ex.hedgeAttempt = 1
+		return transport.RoundTrip(req)
+	}
+
+	if hasBody {
+		// the copies read bodies of their own
+		defer req.Body.Close()
+	}
+
+	results := make(chan hedgeResult, h.maxAttempts())
+	var cancels []context.CancelFunc
+	send := func(attempt int) {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		areq := req.Clone(ctx)
+		// every copy reads its own body, the original one included
+		if hasBody {
+			body, err := req.GetBody()
+			if err != nil {
+				results <- hedgeResult{attempt, nil, err, cancel}
+				return
+			}
+			areq.Body = body
+		}
+		start := time.Now()
+		go func() {
+			res, err := transport.RoundTrip(areq)
+			if err == nil {
+				h.observe(time.Since(start))
+			}
+			results <- hedgeResult{attempt, res, err, cancel}
+		}()
+	}
+
+	sent, pending := 1, 1
+	send(1)
+	timer := time.NewTimer(h.delay())
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-timer.C:
+			if sent < h.maxAttempts() {
+				sent++
+				pending++
+				send(sent)
+				timer.Reset(h.delay())
+			}
+		case result := <-results:
+			pending--
+			if result.err != nil {
+				result.cancel()
+				lastErr = result.err
+				if pending > 0 {
+					continue
+				}
+				if sent < h.maxAttempts() && req.Context().Err() == nil {
+					sent++
+					pending++
+					send(sent)
+					continue
+				}
+				return nil, lastErr
+			}
+			// cancel the other copies and discard whatever they return
+			for i, cancel := range cancels {
+				if i+1 != result.attempt {
+					cancel()
+				}
+			}
+			go drainHedges(results, pending)
+			ex.hedgeAttempt = result.attempt
+			result.res.Body = &cancelOnClose{result.res.Body, result.cancel}
+			return result.res, nil
+		}
+	}
+}
+
+func drainHedges(results chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		result := <-results
+		if result.res != nil {
+			result.res.Body.Close()
+		}
+		result.cancel()
+	}
+}
+
+// cancelOnClose releases the context of the winning copy once its body is
+// closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
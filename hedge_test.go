@@ -0,0 +1,124 @@
+package goreq
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestHedging(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Hedging", func() {
+		var ts *httptest.Server
+		var hits int32
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&hits, 1)
+				body, _ := ioutil.ReadAll(r.Body)
+				if n == 1 || r.URL.Path == "/hang" {
+					select {
+					case <-time.After(500 * time.Millisecond):
+					case <-r.Context().Done():
+						return
+					}
+				}
+				fmt.Fprintf(w, "attempt %d", n)
+				if len(body) > 0 {
+					fmt.Fprintf(w, " with %d bytes", len(body))
+				}
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.BeforeEach(func() {
+			atomic.StoreInt32(&hits, 0)
+		})
+
+		g.It("Should use the first copy that answers", func() {
+			start := time.Now()
+			res, err := Request{Uri: ts.URL, Hedging: &Hedging{Delay: 20 * time.Millisecond}}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := res.Body.ToString()
+			gomega.Expect(str).Should(gomega.Equal("attempt 2"))
+			gomega.Expect(res.HedgeAttempt).Should(gomega.Equal(2))
+			gomega.Expect(time.Since(start)).Should(gomega.BeNumerically("<", 400*time.Millisecond))
+		})
+
+		g.It("Should not hedge requests answering before the delay", func() {
+			atomic.StoreInt32(&hits, 1)
+			res, err := Request{Uri: ts.URL, Hedging: &Hedging{Delay: 200 * time.Millisecond}}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			res.Body.Close()
+			gomega.Expect(res.HedgeAttempt).Should(gomega.Equal(1))
+			gomega.Expect(atomic.LoadInt32(&hits)).Should(gomega.Equal(int32(2)))
+		})
+
+		g.It("Should not hedge non idempotent methods unless told to", func() {
+			hedging := &Hedging{Delay: 20 * time.Millisecond}
+			res, err := Request{Method: "POST", Uri: ts.URL, Body: "foo", Hedging: hedging}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			res.Body.Close()
+			gomega.Expect(res.HedgeAttempt).Should(gomega.Equal(1))
+			gomega.Expect(atomic.LoadInt32(&hits)).Should(gomega.Equal(int32(1)))
+
+			atomic.StoreInt32(&hits, 0)
+			hedging.NonIdempotent = true
+			res, err = Request{Method: "POST", Uri: ts.URL, Body: "foo", Hedging: hedging}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			res.Body.Close()
+			gomega.Expect(res.HedgeAttempt).Should(gomega.Equal(2))
+		})
+
+		g.It("Should give each copy a body of its own", func() {
+			body := struct{ io.ReadSeeker }{bytes.NewReader(make([]byte, 1<<20))}
+			res, err := Request{Method: "PUT", Uri: ts.URL, Body: body, Hedging: &Hedging{Delay: 5 * time.Millisecond}}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := res.Body.ToString()
+			gomega.Expect(res.HedgeAttempt).Should(gomega.Equal(1))
+			gomega.Expect(str).Should(gomega.Equal("attempt 1 with 1048576 bytes"))
+			gomega.Expect(atomic.LoadInt32(&hits)).Should(gomega.Equal(int32(1)))
+
+			atomic.StoreInt32(&hits, 0)
+			file, _ := ioutil.TempFile("", "goreq-hedge")
+			defer os.Remove(file.Name())
+			file.Write(make([]byte, 1<<20))
+			file.Seek(0, io.SeekStart)
+			res, err = Request{Method: "PUT", Uri: ts.URL, Body: file, Hedging: &Hedging{Delay: 5 * time.Millisecond}}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ = res.Body.ToString()
+			gomega.Expect(res.HedgeAttempt).Should(gomega.Equal(2))
+			gomega.Expect(str).Should(gomega.Equal("attempt 2 with 1048576 bytes"))
+		})
+
+		g.It("Should respect the request Timeout", func() {
+			_, err := Request{Uri: ts.URL + "/hang", Timeout: 50 * time.Millisecond, Hedging: &Hedging{Delay: 10 * time.Millisecond, MaxAttempts: 3}}.Do()
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			gomega.Expect(err.(*Error).Timeout()).Should(gomega.BeTrue())
+		})
+
+		g.It("Should compute the delay from the observed latencies", func() {
+			hedging := &Hedging{Delay: time.Second, Percentile: 0.9}
+			gomega.Expect(hedging.delay()).Should(gomega.Equal(time.Second))
+			for i := 1; i <= 100; i++ {
+				hedging.observe(time.Duration(i) * time.Millisecond)
+			}
+			gomega.Expect(hedging.delay()).Should(gomega.Equal(91 * time.Millisecond))
+		})
+	})
+}
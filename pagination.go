@@ -0,0 +1,244 @@
+package goreq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrMaxPages is returned by a PageIterator that stopped because it reached
+// the MaxPages of its Pager while there were more pages.
+var ErrMaxPages = errors.New("Pagination stopped after MaxPages pages")
+
+// NextPage returns the request for the page following res, which was the
+// response to prev, or nil if res was the last page.
+type NextPage func(prev Request, res *Response) (*Request, error)
+
+// Pager fetches a paginated resource, starting with Request and using Next
+// to find the following pages.
+type Pager struct {
+	Request Request
+	// Next defaults to LinkNext().
+	Next NextPage
+	// MaxPages is the maximum number of pages fetched. It defaults to 1000.
+	MaxPages int
+	// Client, if set, is used to send the requests.
+	Client *Client
+}
+
+// PageIterator goes through the pages of a Pager:
+//
+//	it := pager.Iterate(ctx)
+//	for it.Next() {
+//		var items []Item
+//		if err := it.Decode(&items); err != nil {
+//			...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type PageIterator struct {
+	pager *Pager
+	ctx   context.Context
+	next  *Request
+	res   *Response
+	err   error
+	pages int
+}
+
+func (p *Pager) maxPages() int {
+	if p.MaxPages > 0 {
+		return p.MaxPages
+	}
+	return 1000
+}
+
+func (p *Pager) nextPage() NextPage {
+	if p.Next != nil {
+		return p.Next
+	}
+	return LinkNext()
+}
+
+// Iterate returns an iterator over the pages. Requests are sent with ctx
+// and stop as soon as it is cancelled.
+func (p *Pager) Iterate(ctx context.Context) *PageIterator {
+	first := p.Request
+	return &PageIterator{pager: p, ctx: ctx, next: &first}
+}
+
+// Next fetches the next page and reports whether there is one. Once it
+// returns false, Err tells whether the iteration stopped because of an
+// error.
+func (it *PageIterator) Next() bool {
+	if it.err != nil || it.next == nil {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	if it.pages >= it.pager.maxPages() {
+		it.err = ErrMaxPages
+		return false
+	}
+
+	req := *it.next
+	req.Context = it.ctx
+	req.BufferBody = true
+	var res *Response
+	var err error
+	if it.pager.Client != nil {
+		res, err = it.pager.Client.Do(req)
+	} else {
+		res, err = req.Do()
+	}
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if res.StatusCode >= 400 {
+		it.err = &Error{Err: fmt.Errorf("Page request to %s failed with status %d", res.Request.URL, res.StatusCode)}
+		return false
+	}
+	it.res = res
+	it.pages++
+
+	next, err := it.pager.nextPage()(*it.next, res)
+	if err != nil {
+		// the current page is fine, report the error on the next call
+		it.err = err
+	}
+	it.next = next
+	return true
+}
+
+// Response returns the response of the current page.
+func (it *PageIterator) Response() *Response {
+	return it.res
+}
+
+// Decode decodes the JSON body of the current page into v.
+func (it *PageIterator) Decode(v interface{}) error {
+	return it.res.Body.FromJsonTo(v)
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *PageIterator) Err() error {
+	return it.err
+}
+
+// pageRequest returns prev pointed at uri, with the query string already
+// part of uri.
+func pageRequest(prev Request, uri string) *Request {
+	prev.Uri = uri
+	prev.QueryString = nil
+	return &prev
+}
+
+// LinkNext follows the RFC 8288 Link header with rel="next".
+func LinkNext() NextPage {
+	return func(prev Request, res *Response) (*Request, error) {
+		for _, header := range res.Header.Values("Link") {
+			for _, link := range strings.Split(header, ",") {
+				parts := strings.Split(link, ";")
+				target := strings.TrimSpace(parts[0])
+				if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+					continue
+				}
+				for _, param := range parts[1:] {
+					param = strings.TrimSpace(param)
+					if !strings.HasPrefix(strings.ToLower(param), "rel=") {
+						continue
+					}
+					for _, rel := range strings.Fields(strings.Trim(param[4:], `"`)) {
+						if strings.EqualFold(rel, "next") {
+							next, err := res.Request.URL.Parse(target[1 : len(target)-1])
+							if err != nil {
+								return nil, err
+							}
+							return pageRequest(prev, next.String()), nil
+						}
+					}
+				}
+			}
+		}
+		return nil, nil
+	}
+}
+
+// CursorNext reads the cursor of the next page from the JSON body, at the
+// dot separated path field (like "meta.next_cursor"), and sends it in the
+// query parameter param. An empty or missing cursor ends the pagination.
+func CursorNext(field, param string) NextPage {
+	return func(prev Request, res *Response) (*Request, error) {
+		var doc interface{}
+		if err := res.Body.FromJsonTo(&doc); err != nil {
+			return nil, err
+		}
+		for _, key := range strings.Split(field, ".") {
+			obj, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, nil
+			}
+			doc = obj[key]
+		}
+		var cursor string
+		switch v := doc.(type) {
+		case nil:
+			return nil, nil
+		case string:
+			cursor = v
+		case float64:
+			cursor = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("Pagination cursor %q is not a string or a number", field)
+		}
+		if cursor == "" {
+			return nil, nil
+		}
+		return pageRequest(prev, withQueryParam(res.Request.URL, param, cursor)), nil
+	}
+}
+
+// QueryNext adds step to the integer query parameter param, to page with
+// page numbers (step 1) or offsets (step being the page size). A missing
+// parameter counts as 0. more reports whether there may be a page after
+// res; if nil, pagination goes on while the body is a non empty JSON array.
+func QueryNext(param string, step int, more func(res *Response) (bool, error)) NextPage {
+	if more == nil {
+		more = func(res *Response) (bool, error) {
+			var items []json.RawMessage
+			if err := res.Body.FromJsonTo(&items); err != nil {
+				return false, err
+			}
+			return len(items) > 0, nil
+		}
+	}
+	return func(prev Request, res *Response) (*Request, error) {
+		ok, err := more(res)
+		if err != nil || !ok {
+			return nil, err
+		}
+		current := 0
+		if v := res.Request.URL.Query().Get(param); v != "" {
+			if current, err = strconv.Atoi(v); err != nil {
+				return nil, err
+			}
+		}
+		return pageRequest(prev, withQueryParam(res.Request.URL, param, strconv.Itoa(current+step))), nil
+	}
+}
+
+func withQueryParam(u *url.URL, name, value string) string {
+	next := *u
+	query := next.Query()
+	query.Set(name, value)
+	next.RawQuery = query.Encode()
+	return next.String()
+}
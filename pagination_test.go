@@ -0,0 +1,128 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestPagination(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Pagination", func() {
+		var ts *httptest.Server
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				if page == 0 {
+					page = 1
+				}
+				switch r.URL.Path {
+				case "/link":
+					if page < 3 {
+						w.Header().Add("Link", fmt.Sprintf(`</link?page=1>; rel="first", </link?page=%d>; rel="next"`, page+1))
+					}
+					fmt.Fprintf(w, `[%d]`, page)
+				case "/cursor":
+					next := `"` + strconv.Itoa(page+1) + `"`
+					if page == 3 {
+						next = "null"
+					}
+					fmt.Fprintf(w, `{"items": [%d], "meta": {"next": %s}}`, page, next)
+				case "/numbers":
+					if page > 3 {
+						fmt.Fprint(w, `[]`)
+						return
+					}
+					fmt.Fprintf(w, `[%d]`, page)
+				case "/fail":
+					if page > 1 {
+						w.WriteHeader(500)
+						return
+					}
+					w.Header().Add("Link", `</fail?page=2>; rel=next`)
+					fmt.Fprint(w, `[1]`)
+				}
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		collect := func(it *PageIterator, decode func(it *PageIterator) []int) []int {
+			var all []int
+			for it.Next() {
+				all = append(all, decode(it)...)
+			}
+			return all
+		}
+
+		array := func(it *PageIterator) []int {
+			var items []int
+			gomega.Expect(it.Decode(&items)).Should(gomega.Succeed())
+			return items
+		}
+
+		g.It("Should follow Link headers", func() {
+			it := (&Pager{Request: Request{Uri: ts.URL + "/link"}, Next: LinkNext()}).Iterate(context.Background())
+			gomega.Expect(collect(it, array)).Should(gomega.Equal([]int{1, 2, 3}))
+			gomega.Expect(it.Err()).Should(gomega.BeNil())
+		})
+
+		g.It("Should follow Link headers without Next", func() {
+			it := (&Pager{Request: Request{Uri: ts.URL + "/link"}}).Iterate(context.Background())
+			gomega.Expect(collect(it, array)).Should(gomega.Equal([]int{1, 2, 3}))
+			gomega.Expect(it.Err()).Should(gomega.BeNil())
+		})
+
+		g.It("Should follow cursors", func() {
+			it := (&Pager{Request: Request{Uri: ts.URL + "/cursor"}, Next: CursorNext("meta.next", "page")}).Iterate(context.Background())
+			all := collect(it, func(it *PageIterator) []int {
+				var page struct{ Items []int }
+				gomega.Expect(it.Decode(&page)).Should(gomega.Succeed())
+				return page.Items
+			})
+			gomega.Expect(all).Should(gomega.Equal([]int{1, 2, 3}))
+			gomega.Expect(it.Err()).Should(gomega.BeNil())
+		})
+
+		g.It("Should increment page numbers until a page is empty", func() {
+			req := Request{Uri: ts.URL + "/numbers", QueryString: struct{ Page int }{1}}
+			it := (&Pager{Request: req, Next: QueryNext("page", 1, nil)}).Iterate(context.Background())
+			gomega.Expect(collect(it, array)).Should(gomega.Equal([]int{1, 2, 3}))
+			gomega.Expect(it.Err()).Should(gomega.BeNil())
+		})
+
+		g.It("Should stop after MaxPages", func() {
+			it := (&Pager{Request: Request{Uri: ts.URL + "/link"}, Next: LinkNext(), MaxPages: 2}).Iterate(context.Background())
+			gomega.Expect(collect(it, array)).Should(gomega.Equal([]int{1, 2}))
+			gomega.Expect(it.Err()).Should(gomega.Equal(ErrMaxPages))
+		})
+
+		g.It("Should stop on error statuses", func() {
+			it := (&Pager{Request: Request{Uri: ts.URL + "/fail"}, Next: LinkNext()}).Iterate(context.Background())
+			gomega.Expect(collect(it, array)).Should(gomega.Equal([]int{1}))
+			gomega.Expect(it.Err()).ShouldNot(gomega.BeNil())
+			gomega.Expect(it.Response().StatusCode).Should(gomega.Equal(200))
+		})
+
+		g.It("Should stop when the context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			it := (&Pager{Request: Request{Uri: ts.URL + "/link"}, Next: LinkNext(), Client: &Client{}}).Iterate(ctx)
+			gomega.Expect(it.Next()).Should(gomega.BeTrue())
+			cancel()
+			gomega.Expect(it.Next()).Should(gomega.BeFalse())
+			gomega.Expect(errors.Is(it.Err(), context.Canceled)).Should(gomega.BeTrue())
+		})
+	})
+}
@@ -0,0 +1,260 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrOperationFailed is the Err of an OperationError for an operation
+	// that reported a failure.
+	ErrOperationFailed = errors.New("Operation failed")
+	// ErrOperationTimeout is the Err of an OperationError for an operation
+	// that did not complete within the Timeout of the Poller.
+	ErrOperationTimeout = errors.New("Operation did not complete in time")
+)
+
+// OperationError is returned by Poller.Wait when the operation failed or did
+// not complete in time. Response is the last status response received, if
+// any.
+type OperationError struct {
+	Err      error
+	Response *Response
+	Attempts int
+}
+
+func (e *OperationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the operation did not complete in time.
+func (e *OperationError) Timeout() bool {
+	return errors.Is(e.Err, ErrOperationTimeout)
+}
+
+// Poller waits for long-running operations started by a request that got a
+// 202 Accepted response, by polling the URL of its Operation-Location (or
+// Location) header.
+type Poller struct {
+	// Request is the template of the status requests, for example to set
+	// headers. Its Uri, Method and Body are replaced.
+	Request Request
+	// Done decides from a status response whether the operation is over. It
+	// returns an error if the operation failed. The body of res is buffered
+	// and can be decoded as many times as needed. By default the operation
+	// is over once the status response is not a 202.
+	Done func(res *Response) (bool, error)
+	// Interval is the delay before the first poll. It defaults to 1 second
+	// and is multiplied by Multiplier (2 by default) after each poll, up to
+	// MaxInterval (30 seconds by default). A Retry-After header overrides it.
+	Interval    time.Duration
+	Multiplier  float64
+	MaxInterval time.Duration
+	// Timeout, if set, is how long to wait for the operation overall.
+	Timeout time.Duration
+	// Client, if set, is used to send the requests.
+	Client *Client
+}
+
+// StatusField returns a Poller.Done that reads the status of the operation
+// from the JSON body, at the dot separated path field (like
+// "properties.status"). The operation is over once the status is one of
+// succeeded, and failed once it is one of failed. Statuses are compared
+// case insensitively.
+func StatusField(field string, succeeded, failed []string) func(res *Response) (bool, error) {
+	return func(res *Response) (bool, error) {
+		var doc interface{}
+		if err := res.Body.FromJsonTo(&doc); err != nil {
+			return false, err
+		}
+		for _, key := range strings.Split(field, ".") {
+			obj, _ := doc.(map[string]interface{})
+			doc = obj[key]
+		}
+		status, _ := doc.(string)
+		for _, s := range succeeded {
+			if strings.EqualFold(status, s) {
+				return true, nil
+			}
+		}
+		for _, s := range failed {
+			if strings.EqualFold(status, s) {
+				return true, fmt.Errorf("%w with status %s", ErrOperationFailed, status)
+			}
+		}
+		return false, nil
+	}
+}
+
+func (p *Poller) interval() time.Duration {
+	if p.Interval > 0 {
+		return p.Interval
+	}
+	return time.Second
+}
+
+func (p *Poller) multiplier() float64 {
+	if p.Multiplier > 0 {
+		return p.Multiplier
+	}
+	return 2
+}
+
+func (p *Poller) maxInterval() time.Duration {
+	if p.MaxInterval > 0 {
+		return p.MaxInterval
+	}
+	return 30 * time.Second
+}
+
+func (p *Poller) do(r Request) (*Response, error) {
+	if p.Client != nil {
+		return p.Client.Do(r)
+	}
+	return r.Do()
+}
+
+// Wait polls the operation started by res until it is over and returns the
+// final resource: the response at the Location of the last status response
+// if it has one, or the last status response otherwise. If res is not a 202
+// it is returned as is.
+func (p *Poller) Wait(ctx context.Context, res *Response) (*Response, error) {
+	if res.StatusCode != http.StatusAccepted {
+		return res, nil
+	}
+	uri, err := operationLocation(res)
+	if err != nil {
+		return nil, err
+	}
+	if uri == "" {
+		return nil, &Error{Err: errors.New("Accepted response has no Operation-Location or Location header")}
+	}
+
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	done := p.Done
+	if done == nil {
+		done = func(res *Response) (bool, error) {
+			return res.StatusCode != http.StatusAccepted, nil
+		}
+	}
+
+	delay := p.interval()
+	last := res
+	for attempt := 1; ; attempt++ {
+		wait := delay
+		if d, ok := retryAfter(last.Header); ok {
+			wait = d
+		}
+		if err := sleepCtx(ctx, wait); err != nil {
+			return nil, p.stopped(err, last, attempt-1)
+		}
+
+		req := p.Request
+		req.Method = "GET"
+		req.Uri = uri
+		req.QueryString = nil
+		req.Body = nil
+		req.Context = ctx
+		req.BufferBody = true
+		status, err := p.do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, p.stopped(ctx.Err(), last, attempt)
+			}
+			return nil, err
+		}
+		last = status
+		if status.StatusCode >= 400 {
+			return nil, &OperationError{Err: fmt.Errorf("%w with status code %d", ErrOperationFailed, status.StatusCode), Response: status, Attempts: attempt}
+		}
+		over, err := done(status)
+		if err != nil {
+			return nil, &OperationError{Err: err, Response: status, Attempts: attempt}
+		}
+		if over {
+			location := status.Header.Get("Location")
+			if location == "" {
+				return status, nil
+			}
+			target, err := status.Request.URL.Parse(location)
+			if err != nil {
+				return nil, &Error{Err: err}
+			}
+			req.Uri = target.String()
+			return p.do(req)
+		}
+		if next, err := operationLocation(status); err == nil && next != "" && status.StatusCode == http.StatusAccepted {
+			uri = next
+		}
+		delay = time.Duration(float64(delay) * p.multiplier())
+		if delay > p.maxInterval() {
+			delay = p.maxInterval()
+		}
+	}
+}
+
+// stopped reports why polling stopped early: a timeout if the deadline of
+// ctx passed, or the cancellation of ctx.
+func (p *Poller) stopped(err error, last *Response, attempts int) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &OperationError{Err: ErrOperationTimeout, Response: last, Attempts: attempts}
+	}
+	return err
+}
+
+func operationLocation(res *Response) (string, error) {
+	for _, name := range []string{"Operation-Location", "Location"} {
+		if v := res.Header.Get(name); v != "" {
+			u, err := res.Request.URL.Parse(v)
+			if err != nil {
+				return "", &Error{Err: err}
+			}
+			return u.String(), nil
+		}
+	}
+	return "", nil
+}
+
+// retryAfter parses a Retry-After header, either a number of seconds or a
+// date.
+func retryAfter(header http.Header) (time.Duration, bool) {
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
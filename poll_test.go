@@ -0,0 +1,126 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestPoller(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Poller", func() {
+		var ts *httptest.Server
+		var polls int32
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/start":
+					w.Header().Set("Operation-Location", "/operations/1"+r.URL.RawQuery)
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(202)
+				case "/location":
+					w.Header().Set("Location", "/redirect")
+					w.WriteHeader(202)
+				case "/redirect":
+					if atomic.AddInt32(&polls, 1) < 3 {
+						w.WriteHeader(202)
+						return
+					}
+					w.Header().Set("Location", "/resource")
+					w.WriteHeader(303)
+				case "/operations/1":
+					status := "Running"
+					if atomic.AddInt32(&polls, 1) >= 3 {
+						status = "Succeeded"
+					}
+					fmt.Fprintf(w, `{"status": %q}`, status)
+				case "/operations/1fail":
+					fmt.Fprint(w, `{"status": "Failed"}`)
+				case "/operations/1never":
+					fmt.Fprint(w, `{"status": "Running"}`)
+				case "/resource":
+					fmt.Fprint(w, "resource")
+				}
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.BeforeEach(func() {
+			atomic.StoreInt32(&polls, 0)
+		})
+
+		statusDone := StatusField("status", []string{"succeeded"}, []string{"failed"})
+
+		g.It("Should poll until the predicate reports completion", func() {
+			res, _ := Request{Method: "POST", Uri: ts.URL + "/start"}.Do()
+			p := &Poller{Done: statusDone, Interval: time.Millisecond}
+			final, err := p.Wait(context.Background(), res)
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := final.Body.ToString()
+			gomega.Expect(str).Should(gomega.Equal(`{"status": "Succeeded"}`))
+			gomega.Expect(atomic.LoadInt32(&polls)).Should(gomega.Equal(int32(3)))
+		})
+
+		g.It("Should fetch the final resource from the Location header", func() {
+			res, _ := Request{Method: "POST", Uri: ts.URL + "/location"}.Do()
+			final, err := (&Poller{Interval: time.Millisecond}).Wait(context.Background(), res)
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := final.Body.ToString()
+			gomega.Expect(str).Should(gomega.Equal("resource"))
+		})
+
+		g.It("Should return an OperationError for failed operations", func() {
+			res, _ := Request{Method: "POST", Uri: ts.URL + "/start?fail"}.Do()
+			_, err := (&Poller{Done: statusDone}).Wait(context.Background(), res)
+			gomega.Expect(errors.Is(err, ErrOperationFailed)).Should(gomega.BeTrue())
+			var opErr *OperationError
+			gomega.Expect(errors.As(err, &opErr)).Should(gomega.BeTrue())
+			gomega.Expect(opErr.Timeout()).Should(gomega.BeFalse())
+			gomega.Expect(opErr.Attempts).Should(gomega.Equal(1))
+		})
+
+		g.It("Should time out", func() {
+			res, _ := Request{Method: "POST", Uri: ts.URL + "/start?never"}.Do()
+			p := &Poller{Done: statusDone, Interval: 10 * time.Millisecond, Timeout: 100 * time.Millisecond}
+			_, err := p.Wait(context.Background(), res)
+			gomega.Expect(errors.Is(err, ErrOperationTimeout)).Should(gomega.BeTrue())
+			gomega.Expect(err.(*OperationError).Timeout()).Should(gomega.BeTrue())
+		})
+
+		g.It("Should return responses other than 202 as is", func() {
+			res, _ := Request{Uri: ts.URL + "/resource"}.Do()
+			final, err := (&Poller{}).Wait(context.Background(), res)
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(final).Should(gomega.Equal(res))
+		})
+	})
+
+	g.Describe("Retry-After", func() {
+		g.It("Should parse seconds and dates", func() {
+			header := http.Header{}
+			header.Set("Retry-After", "5")
+			d, ok := retryAfter(header)
+			gomega.Expect(ok).Should(gomega.BeTrue())
+			gomega.Expect(d).Should(gomega.Equal(5 * time.Second))
+
+			header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+			d, ok = retryAfter(header)
+			gomega.Expect(ok).Should(gomega.BeTrue())
+			gomega.Expect(d).Should(gomega.BeNumerically("~", time.Hour, 2*time.Second))
+		})
+	})
+}
@@ -0,0 +1,116 @@
+package goreq
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolConfig tunes the connections of a Client. Zero values keep the
+// defaults of http.Transport.
+type PoolConfig struct {
+	// MaxIdleConns limits the idle connections kept for all hosts, and
+	// MaxIdleConnsPerHost the ones kept for each host.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections to each host, whether they are
+	// idle or in use. Requests wait for a connection past the limit.
+	MaxConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept. It defaults
+	// to 90 seconds.
+	IdleConnTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes.
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	// HTTP2 enables HTTP/2 with servers that support it over TLS.
+	HTTP2 bool
+}
+
+func (p PoolConfig) idleConnTimeout() time.Duration {
+	if p.IdleConnTimeout > 0 {
+		return p.IdleConnTimeout
+	}
+	return 90 * time.Second
+}
+
+// PoolStats are counters about the connections of a Client.
+type PoolStats struct {
+	// Open is the number of connections currently open and Dialed the
+	// number of connections opened so far.
+	Open   int64
+	Dialed int64
+	// Requests is the number of requests that got a connection, Reused
+	// how many of them got one that was used before.
+	Requests int64
+	Reused   int64
+}
+
+type poolCounters struct {
+	open, dialed, requests, reused int64
+}
+
+// CloseIdleConnections closes the connections of c that are not in use.
+// Requests of a Client without connection settings (TLS, Pool, DNS, ...)
+// use the package DefaultTransport, which is left alone.
+func (c *Client) CloseIdleConnections() {
+	c.transports.Range(func(_, t interface{}) bool {
+		t.(interface{ CloseIdleConnections() }).CloseIdleConnections()
+		return true
+	})
+}
+
+// Stats returns the counters of the connections of c. Connections of the
+// package DefaultTransport are not counted.
+func (c *Client) Stats() PoolStats {
+	return PoolStats{
+		Open:     atomic.LoadInt64(&c.counters.open),
+		Dialed:   atomic.LoadInt64(&c.counters.dialed),
+		Requests: atomic.LoadInt64(&c.counters.requests),
+		Reused:   atomic.LoadInt64(&c.counters.reused),
+	}
+}
+
+// countConn counts conn as open until it is closed. p may be nil, for
+// transports of requests without a Client.
+func (p *poolCounters) countConn(conn net.Conn, err error) (net.Conn, error) {
+	if err != nil || p == nil {
+		return conn, err
+	}
+	atomic.AddInt64(&p.dialed, 1)
+	atomic.AddInt64(&p.open, 1)
+	return &countedConn{Conn: conn, counters: p}, nil
+}
+
+// countRequests counts the connections requests sent through transport
+// get.
+func (p *poolCounters) countRequests(transport http.RoundTripper) http.RoundTripper {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			atomic.AddInt64(&p.requests, 1)
+			if info.Reused {
+				atomic.AddInt64(&p.reused, 1)
+			}
+		},
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		ctx := httptrace.WithClientTrace(req.Context(), trace)
+		return transport.RoundTrip(req.WithContext(ctx))
+	})
+}
+
+type countedConn struct {
+	net.Conn
+	counters *poolCounters
+	once     sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() {
+		atomic.AddInt64(&c.counters.open, -1)
+	})
+	return c.Conn.Close()
+}
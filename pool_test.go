@@ -0,0 +1,81 @@
+package goreq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestPool(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Connection pool", func() {
+		var ts *httptest.Server
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/slow" {
+					time.Sleep(100 * time.Millisecond)
+				}
+				w.Write([]byte("ok"))
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should count connections and close idle ones", func() {
+			client := &Client{Pool: PoolConfig{MaxIdleConnsPerHost: 2, IdleConnTimeout: time.Minute}}
+			for i := 0; i < 3; i++ {
+				res, err := client.Do(Request{Uri: ts.URL})
+				gomega.Expect(err).Should(gomega.BeNil())
+				res.Body.ToString()
+			}
+			gomega.Expect(client.Stats()).Should(gomega.Equal(PoolStats{Open: 1, Dialed: 1, Requests: 3, Reused: 2}))
+
+			client.CloseIdleConnections()
+			gomega.Expect(client.Stats().Open).Should(gomega.Equal(int64(0)))
+		})
+
+		g.It("Should limit the connections per host", func() {
+			client := &Client{Pool: PoolConfig{MaxConnsPerHost: 1}}
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res, err := client.Do(Request{Uri: ts.URL + "/slow"})
+					gomega.Expect(err).Should(gomega.BeNil())
+					res.Body.ToString()
+				}()
+			}
+			wg.Wait()
+			gomega.Expect(client.Stats().Dialed).Should(gomega.Equal(int64(1)))
+			gomega.Expect(client.Stats().Requests).Should(gomega.Equal(int64(3)))
+		})
+
+		g.It("Should time out waiting for response headers", func() {
+			client := &Client{Pool: PoolConfig{ResponseHeaderTimeout: 20 * time.Millisecond}}
+			_, err := client.Do(Request{Uri: ts.URL + "/slow"})
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			gomega.Expect(err.(*Error).Timeout()).Should(gomega.BeTrue())
+		})
+
+		g.It("Should keep separate pools per client", func() {
+			first := &Client{Pool: PoolConfig{MaxIdleConns: 10}}
+			second := &Client{Pool: PoolConfig{MaxIdleConns: 10}}
+			first.Do(Request{Uri: ts.URL, BufferBody: true})
+			second.Do(Request{Uri: ts.URL, BufferBody: true})
+			gomega.Expect(first.Stats().Dialed).Should(gomega.Equal(int64(1)))
+			gomega.Expect(second.Stats().Dialed).Should(gomega.Equal(int64(1)))
+			gomega.Expect((&Client{}).Stats()).Should(gomega.Equal(PoolStats{}))
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package goreq
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+)
+
+// Protocol selects the HTTP version a Client speaks.
+type Protocol int
+
+const (
+	// DefaultProtocol uses HTTP/1.1, or HTTP/2 over TLS with servers that
+	// support it if the HTTP2 option of the Pool is set.
+	DefaultProtocol Protocol = iota
+	// HTTP1 always uses HTTP/1.1.
+	HTTP1
+	// PreferHTTP2 uses HTTP/2 over TLS with servers that support it and
+	// HTTP/1.1 otherwise.
+	PreferHTTP2
+	// H2C uses HTTP/2 over cleartext connections with prior knowledge, for
+	// http:// URLs, and HTTP/2 over TLS when supported for https:// ones.
+	// HTTP proxies are not used.
+	H2C
+)
+
+// Protocol returns the protocol the response was received with, as an
+// ALPN identifier: "http/1.1", "h2" for HTTP/2 over TLS or "h2c" for
+// HTTP/2 over cleartext.
+func (r Response) Protocol() string {
+	switch {
+	case r.Response == nil || r.ProtoMajor == 0:
+		return ""
+	case r.ProtoMajor == 2 && r.TLS == nil:
+		return "h2c"
+	case r.ProtoMajor == 2:
+		return "h2"
+	}
+	return fmt.Sprintf("http/%d.%d", r.ProtoMajor, r.ProtoMinor)
+}
+
+// configureProtocol sets up t for protocol and returns the transport to
+// use.
+func configureProtocol(t *http.Transport, protocol Protocol) http.RoundTripper {
+	switch protocol {
+	case HTTP1:
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case PreferHTTP2:
+		t.ForceAttemptHTTP2 = true
+	case H2C:
+		t.ForceAttemptHTTP2 = true
+		dial := t.DialContext
+		return &h2cTransport{
+			https: t,
+			h2c: &http2.Transport{
+				AllowHTTP: true,
+				DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+					return dial(ctx, network, addr)
+				},
+			},
+		}
+	}
+	return t
+}
+
+// h2cTransport sends http:// requests with HTTP/2 over cleartext and
+// https:// ones with a regular transport.
+type h2cTransport struct {
+	https *http.Transport
+	h2c   *http2.Transport
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.https.RoundTrip(req)
+}
+
+func (t *h2cTransport) CloseIdleConnections() {
+	t.https.CloseIdleConnections()
+	t.h2c.CloseIdleConnections()
+}
@@ -0,0 +1,108 @@
+package goreq
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestProtocol(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Protocol", func() {
+		var cleartext, secure *httptest.Server
+		var roots *x509.CertPool
+
+		g.Before(func() {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Proto))
+			})
+			cleartext = httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+			secure = httptest.NewUnstartedServer(handler)
+			secure.EnableHTTP2 = true
+			secure.StartTLS()
+			roots = x509.NewCertPool()
+			roots.AddCert(secure.Certificate())
+		})
+
+		g.After(func() {
+			cleartext.Close()
+			secure.Close()
+		})
+
+		proto := func(client *Client, uri string) (string, string) {
+			res, err := client.Do(Request{Uri: uri})
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := res.Body.ToString()
+			return str, res.Protocol()
+		}
+
+		g.It("Should use HTTP/1.1 by default", func() {
+			served, negotiated := proto(&Client{}, cleartext.URL)
+			gomega.Expect(served).Should(gomega.Equal("HTTP/1.1"))
+			gomega.Expect(negotiated).Should(gomega.Equal("http/1.1"))
+
+			served, _ = proto(&Client{TLS: &TLSConfig{RootCAs: roots}}, secure.URL)
+			gomega.Expect(served).Should(gomega.Equal("HTTP/1.1"))
+		})
+
+		g.It("Should use h2c with prior knowledge", func() {
+			client := &Client{Protocol: H2C}
+			for i := 0; i < 2; i++ {
+				served, negotiated := proto(client, cleartext.URL)
+				gomega.Expect(served).Should(gomega.Equal("HTTP/2.0"))
+				gomega.Expect(negotiated).Should(gomega.Equal("h2c"))
+			}
+			gomega.Expect(client.Stats().Dialed).Should(gomega.Equal(int64(1)))
+			client.CloseIdleConnections()
+		})
+
+		g.It("Should dial h2c connections with the request context", func() {
+			hanging := &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}}
+			client := &Client{Protocol: H2C, DNS: &DNS{Resolver: hanging}}
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := client.Do(Request{Uri: "http://h2c.test/", Context: ctx})
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			gomega.Expect(time.Since(start)).Should(gomega.BeNumerically("<", time.Second))
+		})
+
+		g.It("Should still use TLS for https URLs with h2c", func() {
+			_, negotiated := proto(&Client{Protocol: H2C, TLS: &TLSConfig{RootCAs: roots}}, secure.URL)
+			gomega.Expect(negotiated).Should(gomega.Equal("h2"))
+		})
+
+		g.It("Should negotiate HTTP/2 over TLS when preferred", func() {
+			served, negotiated := proto(&Client{Protocol: PreferHTTP2, TLS: &TLSConfig{RootCAs: roots}}, secure.URL)
+			gomega.Expect(served).Should(gomega.Equal("HTTP/2.0"))
+			gomega.Expect(negotiated).Should(gomega.Equal("h2"))
+
+			_, negotiated = proto(&Client{Pool: PoolConfig{HTTP2: true}, TLS: &TLSConfig{RootCAs: roots}}, secure.URL)
+			gomega.Expect(negotiated).Should(gomega.Equal("h2"))
+
+			_, negotiated = proto(&Client{Protocol: PreferHTTP2}, cleartext.URL)
+			gomega.Expect(negotiated).Should(gomega.Equal("http/1.1"))
+		})
+
+		g.It("Should force HTTP/1.1", func() {
+			client := &Client{Protocol: HTTP1, Pool: PoolConfig{HTTP2: true}, TLS: &TLSConfig{RootCAs: roots}}
+			served, negotiated := proto(client, secure.URL)
+			gomega.Expect(served).Should(gomega.Equal("HTTP/1.1"))
+			gomega.Expect(negotiated).Should(gomega.Equal("http/1.1"))
+		})
+	})
+}
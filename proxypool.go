@@ -0,0 +1,192 @@
+package goreq
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyStrategy is the way a ProxyPool picks a proxy for a request.
+type ProxyStrategy int
+
+const (
+	// RoundRobin uses the healthy proxies in turn.
+	RoundRobin ProxyStrategy = iota
+	// RandomProxy picks a healthy proxy at random.
+	RandomProxy
+	// LeastRecentlyFailed picks the proxy whose last failure is the oldest,
+	// proxies that never failed first.
+	LeastRecentlyFailed
+	// StickyPerHost keeps using the same proxy for a host as long as it is
+	// healthy.
+	StickyPerHost
+)
+
+// ProxyPool spreads the requests of a Client across several proxies. A
+// proxy is unhealthy for Cooldown after a connection failure or a 407
+// response, and is only used again if every proxy is unhealthy.
+type ProxyPool struct {
+	// Proxies are proxy URLs, as in Request.Proxy.
+	Proxies  []string
+	Strategy ProxyStrategy
+	// Cooldown is how long a failed proxy is left aside. It defaults to 30
+	// seconds.
+	Cooldown time.Duration
+
+	mu       sync.Mutex
+	next     int
+	failures map[string]time.Time
+	sticky   map[string]string
+}
+
+func (p *ProxyPool) cooldown() time.Duration {
+	if p.Cooldown > 0 {
+		return p.Cooldown
+	}
+	return 30 * time.Second
+}
+
+// Healthy reports whether proxy did not fail within the last Cooldown.
+func (p *ProxyPool) Healthy(proxy string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.healthy(proxy, time.Now())
+}
+
+func (p *ProxyPool) healthy(proxy string, now time.Time) bool {
+	failed, ok := p.failures[proxy]
+	return !ok || now.Sub(failed) >= p.cooldown()
+}
+
+// MarkFailed makes proxy unhealthy for Cooldown.
+func (p *ProxyPool) MarkFailed(proxy string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures == nil {
+		p.failures = make(map[string]time.Time)
+	}
+	p.failures[proxy] = time.Now()
+}
+
+// MarkHealthy forgets the failures of proxy.
+func (p *ProxyPool) MarkHealthy(proxy string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.failures, proxy)
+}
+
+// Pick returns the proxy to use for a request to host.
+func (p *ProxyPool) Pick(host string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.Proxies) == 0 {
+		return ""
+	}
+	now := time.Now()
+	var healthy []string
+	for _, proxy := range p.Proxies {
+		if p.healthy(proxy, now) {
+			healthy = append(healthy, proxy)
+		}
+	}
+	if len(healthy) == 0 {
+		// better try a failed proxy than not sending the request
+		return p.leastRecentlyFailed(p.Proxies)
+	}
+
+	switch p.Strategy {
+	case RandomProxy:
+		return healthy[rand.Intn(len(healthy))]
+	case LeastRecentlyFailed:
+		return p.leastRecentlyFailed(healthy)
+	case StickyPerHost:
+		if proxy, ok := p.sticky[host]; ok && p.healthy(proxy, now) {
+			return proxy
+		}
+		if p.sticky == nil {
+			p.sticky = make(map[string]string)
+		}
+		proxy := p.roundRobin(healthy)
+		p.sticky[host] = proxy
+		return proxy
+	default:
+		return p.roundRobin(healthy)
+	}
+}
+
+func (p *ProxyPool) roundRobin(proxies []string) string {
+	proxy := proxies[p.next%len(proxies)]
+	p.next++
+	return proxy
+}
+
+func (p *ProxyPool) leastRecentlyFailed(proxies []string) string {
+	// start from the rotation so proxies that never failed share the load
+	var best string
+	var bestFailure time.Time
+	for i := range proxies {
+		proxy := proxies[(p.next+i)%len(proxies)]
+		failed := p.failures[proxy]
+		if best == "" || failed.Before(bestFailure) {
+			best, bestFailure = proxy, failed
+		}
+	}
+	p.next++
+	return best
+}
+
+// record updates the health of proxy from the outcome of a round trip.
+func (p *ProxyPool) record(proxy string, res *http.Response, err error) {
+	if isProxyFailure(err) || (res != nil && res.StatusCode == http.StatusProxyAuthRequired) {
+		p.MarkFailed(proxy)
+	} else if err == nil {
+		p.MarkHealthy(proxy)
+	}
+}
+
+// isProxyFailure reports whether err comes from connecting to a proxy
+// rather than to the server behind it.
+func isProxyFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		return true
+	}
+	// CONNECT requests refused by the proxy fail with the status text
+	return strings.Contains(err.Error(), http.StatusText(http.StatusProxyAuthRequired))
+}
+
+// pickProxy sets the proxy of r from the ProxyPool of its client, unless
+// r has a proxy of its own.
+func (r *Request) pickProxy() {
+	if r.Proxy != "" || r.client == nil || r.client.ProxyPool == nil {
+		return
+	}
+	var host string
+	if u, err := url.Parse(r.Uri); err == nil {
+		host = u.Host
+	}
+	r.Proxy = r.client.ProxyPool.Pick(host)
+	r.proxyPool = r.client.ProxyPool
+}
+
+// trackProxy reports the outcome of every round trip of transport to the
+// ProxyPool r picked its proxy from.
+func (r Request) trackProxy(transport http.RoundTripper) http.RoundTripper {
+	if r.proxyPool == nil {
+		return transport
+	}
+	pool, proxy := r.proxyPool, r.Proxy
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		res, err := transport.RoundTrip(req)
+		pool.record(proxy, res, err)
+		return res, err
+	})
+}
@@ -0,0 +1,109 @@
+package goreq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestProxyPool(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("ProxyPool", func() {
+		var first, second, auth *httptest.Server
+		var dead string
+
+		proxy := func(name string) *httptest.Server {
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(name))
+			}))
+		}
+
+		g.Before(func() {
+			first = proxy("first")
+			second = proxy("second")
+			auth = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusProxyAuthRequired)
+			}))
+			closed := httptest.NewServer(http.NotFoundHandler())
+			dead = closed.URL
+			closed.Close()
+		})
+
+		g.After(func() {
+			first.Close()
+			second.Close()
+			auth.Close()
+		})
+
+		via := func(client *Client, uri string) string {
+			res, err := client.Do(Request{Uri: uri})
+			if err != nil {
+				return "error"
+			}
+			str, _ := res.Body.ToString()
+			return str
+		}
+
+		g.It("Should rotate proxies", func() {
+			client := &Client{ProxyPool: &ProxyPool{Proxies: []string{first.URL, second.URL}}}
+			gomega.Expect(via(client, "http://example.com")).Should(gomega.Equal("first"))
+			gomega.Expect(via(client, "http://example.com")).Should(gomega.Equal("second"))
+			gomega.Expect(via(client, "http://example.com")).Should(gomega.Equal("first"))
+		})
+
+		g.It("Should report the proxy on the response", func() {
+			client := &Client{ProxyPool: &ProxyPool{Proxies: []string{second.URL}}}
+			res, err := client.Do(Request{Uri: "http://example.com"})
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.Proxy).Should(gomega.Equal(second.URL))
+		})
+
+		g.It("Should keep the same proxy per host", func() {
+			client := &Client{ProxyPool: &ProxyPool{Proxies: []string{first.URL, second.URL}, Strategy: StickyPerHost}}
+			a := via(client, "http://a.example.com")
+			b := via(client, "http://b.example.com")
+			gomega.Expect(a).ShouldNot(gomega.Equal(b))
+			gomega.Expect(via(client, "http://a.example.com/other")).Should(gomega.Equal(a))
+			gomega.Expect(via(client, "http://b.example.com/other")).Should(gomega.Equal(b))
+		})
+
+		g.It("Should skip proxies that failed to connect", func() {
+			pool := &ProxyPool{Proxies: []string{dead, first.URL}}
+			client := &Client{ProxyPool: pool}
+			gomega.Expect(via(client, "http://example.com")).Should(gomega.Equal("error"))
+			gomega.Expect(pool.Healthy(dead)).Should(gomega.BeFalse())
+			for i := 0; i < 3; i++ {
+				gomega.Expect(via(client, "http://example.com")).Should(gomega.Equal("first"))
+			}
+		})
+
+		g.It("Should skip proxies answering 407", func() {
+			pool := &ProxyPool{Proxies: []string{auth.URL, second.URL}, Strategy: LeastRecentlyFailed}
+			client := &Client{ProxyPool: pool}
+			res, _ := client.Do(Request{Uri: "http://example.com"})
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(407))
+			gomega.Expect(pool.Healthy(auth.URL)).Should(gomega.BeFalse())
+			gomega.Expect(via(client, "http://example.com")).Should(gomega.Equal("second"))
+			gomega.Expect(via(client, "http://example.com")).Should(gomega.Equal("second"))
+		})
+
+		g.It("Should use failed proxies if no proxy is healthy", func() {
+			pool := &ProxyPool{Proxies: []string{first.URL}}
+			pool.MarkFailed(first.URL)
+			gomega.Expect(via(&Client{ProxyPool: pool}, "http://example.com")).Should(gomega.Equal("first"))
+			gomega.Expect(pool.Healthy(first.URL)).Should(gomega.BeTrue())
+		})
+
+		g.It("Should prefer the proxy of the request", func() {
+			client := &Client{ProxyPool: &ProxyPool{Proxies: []string{first.URL}}}
+			res, _ := client.Do(Request{Uri: "http://example.com", Proxy: second.URL})
+			str, _ := res.Body.ToString()
+			gomega.Expect(str).Should(gomega.Equal("second"))
+		})
+	})
+}
@@ -0,0 +1,176 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a request can not be sent without
+// exceeding its RateLimiter, either because FailFast is set or because
+// waiting would go past the request deadline.
+var ErrRateLimited = errors.New("Rate limit exceeded")
+
+// RateLimiter is a token bucket limiter. Every key (by default the host
+// of the request) gets its own bucket that holds up to Burst tokens and is
+// refilled at Rate tokens per second. Each request, including redirect
+// hops, takes a token.
+type RateLimiter struct {
+	// Rate is the number of requests per second allowed for each key.
+	Rate float64
+	// Burst is the number of requests that can be sent at once. It
+	// defaults to 1.
+	Burst int
+	// FailFast makes requests fail with ErrRateLimited instead of waiting
+	// for a token.
+	FailFast bool
+	// Key returns the bucket a request belongs to. It defaults to the
+	// request host.
+	Key func(req *http.Request) string
+	// Adaptive adjusts the buckets from the X-RateLimit-Remaining,
+	// RateLimit-Remaining, X-RateLimit-Reset and RateLimit-Reset headers of
+	// the responses, so no more requests are sent than the server allows.
+	Adaptive bool
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens  float64
+	last    time.Time
+	blocked time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing rate requests per second
+// with bursts of up to burst requests for each host.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{Rate: rate, Burst: burst}
+}
+
+func (l *RateLimiter) key(req *http.Request) string {
+	if l.Key != nil {
+		return l.Key(req)
+	}
+	return req.URL.Host
+}
+
+func (l *RateLimiter) burst() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+// reserve takes a token from the bucket of key if there is one. Otherwise
+// it returns how long to wait before trying again.
+func (l *RateLimiter) reserve(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*bucket)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst(), last: now}
+		l.buckets[key] = b
+	}
+	if now.Before(b.blocked) {
+		return b.blocked.Sub(now)
+	}
+	if l.Rate > 0 {
+		b.tokens += now.Sub(b.last).Seconds() * l.Rate
+		if b.tokens > l.burst() {
+			b.tokens = l.burst()
+		}
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	if l.Rate <= 0 {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+}
+
+// Wait blocks until a request with the given key can be sent or ctx is
+// done.
+func (l *RateLimiter) Wait(ctx context.Context, key string) error {
+	for {
+		wait := l.reserve(key, time.Now())
+		if wait == 0 {
+			return nil
+		}
+		if l.FailFast {
+			return ErrRateLimited
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return ErrRateLimited
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *RateLimiter) roundTrip(transport http.RoundTripper, req *http.Request) (*http.Response, error) {
+	key := l.key(req)
+	if err := l.Wait(req.Context(), key); err != nil {
+		return nil, err
+	}
+	res, err := transport.RoundTrip(req)
+	if err == nil && l.Adaptive {
+		l.adjust(key, res.Header, time.Now())
+	}
+	return res, err
+}
+
+// adjust updates the bucket of key from the rate limit headers sent by the
+// server.
+func (l *RateLimiter) adjust(key string, header http.Header, now time.Time) {
+	remaining, ok := headerInt(header, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		return
+	}
+	if float64(remaining) < b.tokens {
+		b.tokens = float64(remaining)
+	}
+	if remaining > 0 {
+		return
+	}
+	if reset, ok := headerInt(header, "X-RateLimit-Reset", "RateLimit-Reset"); ok {
+		// big values are unix timestamps, small ones are seconds to wait
+		if reset > 1000000000 {
+			b.blocked = time.Unix(reset, 0)
+		} else {
+			b.blocked = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+}
+
+func headerInt(header http.Header, names ...string) (int64, bool) {
+	for _, name := range names {
+		if v := header.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err == nil {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
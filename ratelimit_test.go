@@ -0,0 +1,108 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestRateLimiter(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("RateLimiter", func() {
+		var ts *httptest.Server
+		var remaining string
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if remaining != "" {
+					w.Header().Set("X-RateLimit-Remaining", remaining)
+					w.Header().Set("X-RateLimit-Reset", "1")
+				}
+				w.WriteHeader(200)
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.BeforeEach(func() {
+			remaining = ""
+		})
+
+		g.It("Should let bursts through and then wait for tokens", func() {
+			limiter := NewRateLimiter(20, 2)
+			start := time.Now()
+			for i := 0; i < 3; i++ {
+				res, err := Request{Uri: ts.URL, RateLimiter: limiter}.Do()
+				gomega.Expect(err).Should(gomega.BeNil())
+				res.Body.Close()
+			}
+			gomega.Expect(time.Since(start)).Should(gomega.BeNumerically(">=", 40*time.Millisecond))
+		})
+
+		g.It("Should fail with ErrRateLimited if FailFast is set", func() {
+			limiter := &RateLimiter{Rate: 1, Burst: 1, FailFast: true}
+			client := &Client{RateLimiter: limiter}
+			res, err := client.Do(Request{Uri: ts.URL})
+			gomega.Expect(err).Should(gomega.BeNil())
+			res.Body.Close()
+
+			_, err = client.Do(Request{Uri: ts.URL})
+			gomega.Expect(errors.Is(err, ErrRateLimited)).Should(gomega.BeTrue())
+		})
+
+		g.It("Should use separate buckets per key", func() {
+			limiter := &RateLimiter{Rate: 1, Burst: 1, FailFast: true}
+			gomega.Expect(limiter.Wait(context.Background(), "a")).Should(gomega.BeNil())
+			gomega.Expect(limiter.Wait(context.Background(), "b")).Should(gomega.BeNil())
+			gomega.Expect(limiter.Wait(context.Background(), "a")).Should(gomega.Equal(ErrRateLimited))
+		})
+
+		g.It("Should stop waiting when the context is cancelled", func() {
+			limiter := NewRateLimiter(0.1, 1)
+			limiter.Wait(context.Background(), "a")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				cancel()
+			}()
+			gomega.Expect(limiter.Wait(ctx, "a")).Should(gomega.Equal(context.Canceled))
+		})
+
+		g.It("Should not wait past the request deadline", func() {
+			limiter := NewRateLimiter(0.1, 1)
+			client := &Client{RateLimiter: limiter}
+			res, _ := client.Do(Request{Uri: ts.URL})
+			res.Body.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := client.Do(Request{Uri: ts.URL, Context: ctx})
+			gomega.Expect(errors.Is(err, ErrRateLimited)).Should(gomega.BeTrue())
+			gomega.Expect(time.Since(start)).Should(gomega.BeNumerically("<", 50*time.Millisecond))
+		})
+
+		g.It("Should adjust from rate limit headers if Adaptive is set", func() {
+			limiter := &RateLimiter{Rate: 100, Burst: 10, Adaptive: true}
+			remaining = "0"
+			res, err := Request{Uri: ts.URL, RateLimiter: limiter}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			res.Body.Close()
+
+			limiter.FailFast = true
+			_, err = Request{Uri: ts.URL, RateLimiter: limiter}.Do()
+			gomega.Expect(errors.Is(err, ErrRateLimited)).Should(gomega.BeTrue())
+		})
+	})
+}
@@ -0,0 +1,74 @@
+package goreq
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// RedirectHop describes one of the intermediate responses that were
+// followed before reaching the final Response.
+type RedirectHop struct {
+	Uri        string
+	StatusCode int
+	Header     http.Header
+}
+
+// RedirectPolicy is called before following each redirect allowed by
+// MaxRedirects. req is the request about to be sent and via the requests
+// made so far, oldest first. Returning an error stops the redirect and is
+// returned by Do; returning http.ErrUseLastResponse stops it and returns
+// the redirect response without an error.
+type RedirectPolicy func(req *http.Request, via []*http.Request) error
+
+// credentialHeaders are removed from a redirected request when it goes to
+// another origin.
+var credentialHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2"}
+
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && a.Host == b.Host
+}
+
+// prepareRedirect adjusts req, the next request of a redirect chain, as
+// configured in r.
+func (r Request) prepareRedirect(req *http.Request, via []*http.Request) error {
+	first, last := via[0], via[len(via)-1]
+
+	//By default Golang will not redirect request headers
+	// https://code.google.com/p/go/issues/detail?id=4800&q=request%20header
+	if r.RedirectHeaders {
+		for key, val := range first.Header {
+			req.Header[key] = val
+		}
+	}
+
+	if !sameOrigin(req.URL, first.URL) {
+		for _, name := range credentialHeaders {
+			req.Header.Del(name)
+		}
+	}
+
+	// Go turns 301 and 302 redirects of non GET requests into a GET
+	// without body. Restore the original method and body if asked to.
+	if r.RedirectKeepMethod && req.Response != nil && req.Method != last.Method {
+		switch req.Response.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound:
+			if last.GetBody == nil && last.ContentLength != 0 {
+				return ErrBodyNotReplayable
+			}
+			req.Method = last.Method
+			if last.GetBody != nil {
+				body, err := last.GetBody()
+				if err != nil {
+					return err
+				}
+				req.Body = body
+				req.GetBody = last.GetBody
+				req.ContentLength = last.ContentLength
+			}
+			if ct := last.Header.Get("Content-Type"); ct != "" && req.Header.Get("Content-Type") == "" {
+				req.Header.Set("Content-Type", ct)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package goreq
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestRedirects(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Redirect policy", func() {
+		var ts, other *httptest.Server
+		var lastHeaders http.Header
+		var lastMethod string
+
+		g.Before(func() {
+			other = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lastHeaders = r.Header
+				w.WriteHeader(200)
+			}))
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/a":
+					w.Header().Set("X-Hop", "a")
+					http.Redirect(w, r, "/b", 301)
+				case "/b":
+					w.Header().Set("X-Hop", "b")
+					http.Redirect(w, r, "/echo", 302)
+				case "/other":
+					http.Redirect(w, r, other.URL+"/", 302)
+				case "/echo":
+					lastHeaders = r.Header
+					lastMethod = r.Method
+					w.WriteHeader(200)
+					io.Copy(w, r.Body)
+				}
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+			other.Close()
+		})
+
+		g.It("Should record every redirect in the response history", func() {
+			res, err := Request{Uri: ts.URL + "/a", MaxRedirects: 2}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.Uri).Should(gomega.Equal(ts.URL + "/echo"))
+			gomega.Expect(res.History).Should(gomega.HaveLen(2))
+			gomega.Expect(res.History[0].Uri).Should(gomega.Equal(ts.URL + "/a"))
+			gomega.Expect(res.History[0].StatusCode).Should(gomega.Equal(301))
+			gomega.Expect(res.History[0].Header.Get("X-Hop")).Should(gomega.Equal("a"))
+			gomega.Expect(res.History[1].Uri).Should(gomega.Equal(ts.URL + "/b"))
+			gomega.Expect(res.History[1].StatusCode).Should(gomega.Equal(302))
+		})
+
+		g.It("Should call the redirect policy for every hop", func() {
+			var hops []string
+			policy := func(req *http.Request, via []*http.Request) error {
+				hops = append(hops, req.URL.Path)
+				if req.URL.Path == "/echo" {
+					return errors.New("not allowed")
+				}
+				return nil
+			}
+			_, err := Request{Uri: ts.URL + "/a", MaxRedirects: 5, RedirectPolicy: policy}.Do()
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			gomega.Expect(hops).Should(gomega.Equal([]string{"/b", "/echo"}))
+		})
+
+		g.It("Should return the redirect response when the policy asks for it", func() {
+			policy := func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+			res, err := Request{Uri: ts.URL + "/a", MaxRedirects: 5, RedirectPolicy: policy}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(301))
+		})
+
+		g.It("Should keep method and body on 301 and 302 if specified", func() {
+			res, err := Request{Method: "POST", Uri: ts.URL + "/a", Body: "foo bar", MaxRedirects: 2}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(lastMethod).Should(gomega.Equal("GET"))
+
+			res, err = Request{Method: "POST", Uri: ts.URL + "/a", Body: "foo bar", MaxRedirects: 2, RedirectKeepMethod: true}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(lastMethod).Should(gomega.Equal("POST"))
+			str, _ := res.Body.ToString()
+			gomega.Expect(str).Should(gomega.Equal("foo bar"))
+		})
+
+		g.It("Should not copy credentials to another origin", func() {
+			req := Request{
+				Uri:               ts.URL + "/other",
+				MaxRedirects:      1,
+				RedirectHeaders:   true,
+				BasicAuthUsername: "user",
+				BasicAuthPassword: "pass",
+			}
+			req.AddHeader("Cookie", "session=secret")
+			req.AddHeader("X-Custom", "foobar")
+			_, err := req.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(lastHeaders.Get("X-Custom")).Should(gomega.Equal("foobar"))
+			gomega.Expect(lastHeaders.Get("Authorization")).Should(gomega.BeEmpty())
+			gomega.Expect(lastHeaders.Get("Cookie")).Should(gomega.BeEmpty())
+		})
+
+		g.It("Should keep credentials on the same origin", func() {
+			_, err := Request{
+				Uri:               ts.URL + "/b",
+				MaxRedirects:      1,
+				RedirectHeaders:   true,
+				BasicAuthUsername: "user",
+				BasicAuthPassword: "pass",
+			}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(lastHeaders.Get("Authorization")).ShouldNot(gomega.BeEmpty())
+		})
+	})
+}
@@ -0,0 +1,184 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errFileChanged is returned by a segment whose Range request got the whole
+// file, because it changed on the server or ranges are not supported after
+// all. The download starts over with a single stream.
+var errFileChanged = errors.New("File changed during the download")
+
+func (d Download) segmentRetries() int {
+	if d.SegmentRetries > 0 {
+		return d.SegmentRetries
+	}
+	return 3
+}
+
+// probe sends a HEAD request for the file and reports whether it can be
+// fetched in segments: the server accepts byte ranges and its size is
+// known.
+func (d Download) probe(ctx context.Context) (*Response, bool) {
+	req := d.Request
+	req.Method = "HEAD"
+	req.Context = ctx
+	res, err := d.send(req)
+	if err != nil {
+		return nil, false
+	}
+	res.Body.Close()
+	ok := res.StatusCode == http.StatusOK && res.ContentLength > 0 &&
+		strings.Contains(strings.ToLower(res.Header.Get("Accept-Ranges")), "bytes")
+	return res, ok
+}
+
+// segmented downloads the file described by head in d.Segments parts
+// written in place in part. A segment that fails is fetched again from
+// where it stopped, and the whole download fails once a segment runs out
+// of retries.
+func (d Download) segmented(ctx context.Context, head *Response, dir, part, validatorPath string) (string, error) {
+	os.Remove(validatorPath)
+	size := head.ContentLength
+	segments := int64(d.Segments)
+	if segments > size {
+		segments = size
+	}
+
+	file, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", err
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		os.Remove(part)
+		return "", err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	var written int64
+	progress := func(n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		written += int64(n)
+		if d.Progress != nil {
+			d.Progress(written, size)
+		}
+	}
+
+	validator := downloadValidator(head.Header)
+	errs := make(chan error, segments)
+	length := size / segments
+	for i := int64(0); i < segments; i++ {
+		start, end := i*length, (i+1)*length-1
+		if i == segments-1 {
+			end = size - 1
+		}
+		w := &segmentWriter{file: file, offset: start, progress: progress}
+		go func(end int64) {
+			err := d.fetchSegment(ctx, w, end, validator)
+			if err != nil {
+				cancel()
+			}
+			errs <- err
+		}(end)
+	}
+	for i := int64(0); i < segments; i++ {
+		// report the segment that failed rather than the ones it cancelled
+		if e := <-errs; e != nil && (err == nil || errors.Is(err, context.Canceled)) {
+			err = e
+		}
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(part)
+		return "", err
+	}
+
+	hashes, err := d.hashes(part, size)
+	if err != nil {
+		return "", err
+	}
+	return d.finish(head, dir, part, validatorPath, written, size, hashes)
+}
+
+// fetchSegment fetches the bytes of the file from w.offset to end,
+// retrying from where the previous attempt stopped, a little later after
+// each failure.
+func (d Download) fetchSegment(ctx context.Context, w *segmentWriter, end int64, validator string) error {
+	var err error
+	for attempt := 0; attempt <= d.segmentRetries(); attempt++ {
+		if attempt > 0 {
+			if err := sleepCtx(ctx, time.Duration(attempt)*100*time.Millisecond); err != nil {
+				return err
+			}
+		}
+		err = d.fetchRange(ctx, w, end, validator)
+		if err == nil || ctx.Err() != nil || errors.Is(err, errFileChanged) {
+			return err
+		}
+	}
+	return err
+}
+
+func (d Download) fetchRange(ctx context.Context, w *segmentWriter, end int64, validator string) error {
+	req := d.Request
+	req.Method = "GET"
+	req.Context = ctx
+	req.BufferBody = false
+	req = req.WithHeader("Range", fmt.Sprintf("bytes=%d-%d", w.offset, end))
+	if validator != "" {
+		// the server sends the whole file if it changed
+		req = req.WithHeader("If-Range", validator)
+	}
+	res, err := d.send(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return errFileChanged
+	}
+	if res.StatusCode != http.StatusPartialContent {
+		return &Error{Err: fmt.Errorf("Range request failed with status %d", res.StatusCode)}
+	}
+	if start, _, _, ok := parseContentRange(res.Header.Get("Content-Range")); !ok || start != w.offset {
+		return &Error{Err: fmt.Errorf("Unexpected Content-Range %q fetching from %d", res.Header.Get("Content-Range"), w.offset)}
+	}
+	expected := end - w.offset + 1
+	n, err := io.Copy(w, io.LimitReader(res.Body, expected))
+	if err != nil {
+		return newError(err)
+	}
+	if n != expected {
+		return newError(io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+// segmentWriter writes a segment in place in the file, and keeps the
+// offset of the next byte so a failed segment can resume.
+type segmentWriter struct {
+	file     *os.File
+	offset   int64
+	progress func(n int)
+}
+
+func (w *segmentWriter) Write(p []byte) (int, error) {
+	n, err := w.file.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	w.progress(n)
+	return n, err
+}
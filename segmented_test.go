@@ -0,0 +1,172 @@
+package goreq
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestSegmentedDownload(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	content := []byte(strings.Repeat("abcdefghij", 10000))
+	sum := sha256.Sum256(content)
+	contentSHA256 := hex.EncodeToString(sum[:])
+
+	g.Describe("Segmented downloads", func() {
+		var ts *httptest.Server
+		var dir string
+		var mu sync.Mutex
+		var ranges []string
+		var gets, flaky int32
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "GET" {
+					atomic.AddInt32(&gets, 1)
+				}
+				if rng := r.Header.Get("Range"); rng != "" {
+					mu.Lock()
+					ranges = append(ranges, rng)
+					mu.Unlock()
+				}
+				switch r.URL.Path {
+				case "/norange":
+					w.Write(content)
+					return
+				case "/flaky":
+					// cut the first response of the second segment short
+					if strings.HasPrefix(r.Header.Get("Range"), "bytes=25000-") && atomic.AddInt32(&flaky, 1) == 1 {
+						w.Header().Set("Content-Range", "bytes 25000-49999/100000")
+						w.Header().Set("Content-Length", "25000")
+						w.WriteHeader(http.StatusPartialContent)
+						w.Write(content[25000:30000])
+						return
+					}
+				case "/changing":
+					// the file changes between the HEAD request and the segments
+					etag := `"v2"`
+					if r.Method == "HEAD" {
+						etag = `"v1"`
+					}
+					w.Header().Set("ETag", etag)
+					http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+					return
+				case "/broken":
+					if r.Header.Get("Range") != "" && r.Header.Get("Range") != "bytes=0-49999" {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+				}
+				w.Header().Set("ETag", `"v1"`)
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "goreq-segmented")
+			ranges = nil
+			atomic.StoreInt32(&gets, 0)
+			atomic.StoreInt32(&flaky, 0)
+		})
+
+		g.AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		g.It("Should fetch the file in concurrent ranges", func() {
+			var mu sync.Mutex
+			var written int64
+			path, err := Download{
+				Request:  Request{Uri: ts.URL + "/file"},
+				Path:     filepath.Join(dir, "file"),
+				Segments: 4,
+				SHA256:   contentSHA256,
+				Progress: func(w, total int64) {
+					mu.Lock()
+					written = w
+					mu.Unlock()
+					gomega.Expect(total).Should(gomega.Equal(int64(len(content))))
+				},
+			}.Do(context.Background())
+			gomega.Expect(err).Should(gomega.BeNil())
+			data, _ := ioutil.ReadFile(path)
+			gomega.Expect(data).Should(gomega.Equal(content))
+			gomega.Expect(written).Should(gomega.Equal(int64(len(content))))
+			gomega.Expect(ranges).Should(gomega.ConsistOf(
+				"bytes=0-24999", "bytes=25000-49999", "bytes=50000-74999", "bytes=75000-99999"))
+		})
+
+		g.It("Should retry a failed segment from where it stopped", func() {
+			path, err := Download{
+				Request:  Request{Uri: ts.URL + "/flaky"},
+				Path:     filepath.Join(dir, "file"),
+				Segments: 4,
+				SHA256:   contentSHA256,
+			}.Do(context.Background())
+			gomega.Expect(err).Should(gomega.BeNil())
+			data, _ := ioutil.ReadFile(path)
+			gomega.Expect(data).Should(gomega.Equal(content))
+			gomega.Expect(ranges).Should(gomega.ContainElement("bytes=30000-49999"))
+		})
+
+		g.It("Should fail once a segment runs out of retries", func() {
+			_, err := Download{
+				Request:        Request{Uri: ts.URL + "/broken"},
+				Path:           filepath.Join(dir, "file"),
+				Segments:       2,
+				SegmentRetries: 1,
+			}.Do(context.Background())
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			gomega.Expect(err.Error()).Should(gomega.ContainSubstring("status 500"))
+			files, _ := ioutil.ReadDir(dir)
+			gomega.Expect(files).Should(gomega.BeEmpty())
+		})
+
+		g.It("Should start over with a single stream if the file changed", func() {
+			path, err := Download{
+				Request:  Request{Uri: ts.URL + "/changing"},
+				Path:     filepath.Join(dir, "file"),
+				Segments: 4,
+				SHA256:   contentSHA256,
+			}.Do(context.Background())
+			gomega.Expect(err).Should(gomega.BeNil())
+			data, _ := ioutil.ReadFile(path)
+			gomega.Expect(data).Should(gomega.Equal(content))
+			// at most one attempt per segment, then the single stream
+			gomega.Expect(atomic.LoadInt32(&gets)).Should(gomega.BeNumerically("<=", 5))
+		})
+
+		g.It("Should fall back to a single stream without range support", func() {
+			path, err := Download{
+				Request:  Request{Uri: ts.URL + "/norange"},
+				Path:     filepath.Join(dir, "file"),
+				Segments: 4,
+			}.Do(context.Background())
+			gomega.Expect(err).Should(gomega.BeNil())
+			data, _ := ioutil.ReadFile(path)
+			gomega.Expect(data).Should(gomega.Equal(content))
+			gomega.Expect(atomic.LoadInt32(&gets)).Should(gomega.Equal(int32(1)))
+			gomega.Expect(ranges).Should(gomega.BeEmpty())
+		})
+	})
+}
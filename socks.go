@@ -0,0 +1,233 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// socksDialer connects through a SOCKS5 proxy (RFC 1928), authenticating
+// with a username and password (RFC 1929) if the proxy URL has some. With
+// remoteDNS (socks5h://) host names are resolved by the proxy, unless an
+// SSRFGuard checks them, otherwise they are resolved locally and the proxy
+// gets an IP address.
+type socksDialer struct {
+	proxy     *url.URL
+	remoteDNS bool
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	dns       *DNS
+	// guard, if set, checks the destinations
+	guard *addressGuard
+}
+
+func isSocks(proxy string) bool {
+	u, err := url.Parse(proxy)
+	return err == nil && (u.Scheme == "socks5" || u.Scheme == "socks5h")
+}
+
+func newSocksDialer(proxy *url.URL, dial func(ctx context.Context, network, addr string) (net.Conn, error), dns *DNS) *socksDialer {
+	return &socksDialer{proxy: proxy, remoteDNS: proxy.Scheme == "socks5h", dial: dial, dns: dns}
+}
+
+func (d *socksDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	proxyAddr := d.proxy.Host
+	if d.proxy.Port() == "" {
+		proxyAddr = net.JoinHostPort(d.proxy.Hostname(), "1080")
+	}
+	conn, err := d.dial(ctx, network, proxyAddr)
+	if err != nil {
+		return nil, &net.OpError{Op: "proxyconnect", Net: "tcp", Err: err}
+	}
+
+	// the handshake is bound to ctx like the dial itself
+	defer conn.SetDeadline(time.Time{})
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	if err := d.connect(ctx, conn, addr); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		err = fmt.Errorf("SOCKS5 proxy %s: %w", proxyAddr, err)
+		if _, ok := errors.Unwrap(err).(socksReplyError); !ok && !errors.Is(err, ErrBlockedAddress) {
+			// the proxy itself failed, not the destination
+			err = &net.OpError{Op: "proxyconnect", Net: "tcp", Err: err}
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (d *socksDialer) connect(ctx context.Context, conn net.Conn, addr string) error {
+	methods := []byte{0x00}
+	if d.proxy.User != nil {
+		methods = append(methods, 0x02)
+	}
+	if _, err := conn.Write(append([]byte{5, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 5 {
+		return errors.New("not a SOCKS5 server")
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if d.proxy.User == nil {
+			return errors.New("authentication required")
+		}
+		user := d.proxy.User.Username()
+		pass, _ := d.proxy.User.Password()
+		if len(user) > 255 || len(pass) > 255 {
+			return errors.New("username or password too long")
+		}
+		auth := []byte{1, byte(len(user))}
+		auth = append(auth, user...)
+		auth = append(auth, byte(len(pass)))
+		auth = append(auth, pass...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("authentication failed")
+		}
+	default:
+		return errors.New("no acceptable authentication method")
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+	req := []byte{5, 1, 0}
+	ip := net.ParseIP(host)
+	// with a guard the address that was checked is sent, so the proxy can
+	// not resolve the name to another one
+	if ip == nil && (!d.remoteDNS || d.guard != nil) {
+		if ip, err = d.lookup(ctx, host, portStr); err != nil {
+			return err
+		}
+	}
+	if d.guard != nil {
+		if err := d.guard.check(ip); err != nil {
+			return err
+		}
+	}
+	switch {
+	case ip.To4() != nil:
+		req = append(append(req, 1), ip.To4()...)
+	case ip != nil:
+		req = append(append(req, 4), ip.To16()...)
+	default:
+		if len(host) > 255 {
+			return errors.New("host name too long")
+		}
+		req = append(append(req, 3, byte(len(host))), host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		return socksReplyError(head[1])
+	}
+	// skip the bound address
+	var skip int
+	switch head[3] {
+	case 1:
+		skip = net.IPv4len + 2
+	case 4:
+		skip = net.IPv6len + 2
+	case 3:
+		if _, err := io.ReadFull(conn, head[:1]); err != nil {
+			return err
+		}
+		skip = int(head[0]) + 2
+	default:
+		return errors.New("invalid reply")
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip))
+	return err
+}
+
+// lookup resolves host for the proxy, with the DNS settings if any or
+// preferring IPv4 addresses otherwise.
+func (d *socksDialer) lookup(ctx context.Context, host, port string) (net.IP, error) {
+	if d.dns != nil {
+		ips, err := d.dns.Lookup(ctx, host, port)
+		if err != nil {
+			return nil, err
+		}
+		return ips[0], nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			return addr.IP, nil
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address found for %s", host)
+	}
+	return addrs[0].IP, nil
+}
+
+// socksReplyError is a failure to reach the destination reported by the
+// proxy.
+type socksReplyError byte
+
+func (e socksReplyError) Error() string {
+	if msg, ok := socksReplies[byte(e)]; ok {
+		return msg
+	}
+	return fmt.Sprintf("reply code %d", byte(e))
+}
+
+var socksReplies = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
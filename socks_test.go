@@ -0,0 +1,173 @@
+package goreq
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+// socksServer is a minimal SOCKS5 proxy recording the destinations it is
+// asked to connect to.
+type socksServer struct {
+	listener net.Listener
+	user     string
+	pass     string
+
+	mu      sync.Mutex
+	targets []string
+}
+
+func newSocksServer(user, pass string) *socksServer {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	s := &socksServer{listener: l, user: user, pass: pass}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *socksServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *socksServer) Close() {
+	s.listener.Close()
+}
+
+func (s *socksServer) Targets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.targets...)
+}
+
+func (s *socksServer) serve(conn net.Conn) {
+	defer conn.Close()
+	buf := make([]byte, 256)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	io.ReadFull(conn, buf[:buf[1]])
+	if s.user == "" {
+		conn.Write([]byte{5, 0})
+	} else {
+		conn.Write([]byte{5, 2})
+		io.ReadFull(conn, buf[:2])
+		user := make([]byte, buf[1])
+		io.ReadFull(conn, user)
+		io.ReadFull(conn, buf[:1])
+		pass := make([]byte, buf[0])
+		io.ReadFull(conn, pass)
+		if string(user) != s.user || string(pass) != s.pass {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	io.ReadFull(conn, buf[:4])
+	var host string
+	switch buf[3] {
+	case 1:
+		io.ReadFull(conn, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 3:
+		io.ReadFull(conn, buf[:1])
+		name := make([]byte, buf[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	case 4:
+		io.ReadFull(conn, buf[:16])
+		host = net.IP(buf[:16]).String()
+	}
+	io.ReadFull(conn, buf[:2])
+	target := net.JoinHostPort(host, strconv.Itoa(int(buf[0])<<8|int(buf[1])))
+	s.mu.Lock()
+	s.targets = append(s.targets, target)
+	s.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+func TestSocks(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("SOCKS5 proxy", func() {
+		var ts *httptest.Server
+		var port string
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Host))
+			}))
+			_, port, _ = net.SplitHostPort(ts.Listener.Addr().String())
+		})
+
+		g.After(func() {
+			ts.Close()
+		})
+
+		g.It("Should resolve host names locally with socks5", func() {
+			proxy := newSocksServer("", "")
+			defer proxy.Close()
+			res, err := Request{Uri: "http://localhost:" + port, Proxy: "socks5://" + proxy.Addr()}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := res.Body.ToString()
+			gomega.Expect(str).Should(gomega.Equal("localhost:" + port))
+			gomega.Expect(proxy.Targets()).Should(gomega.Equal([]string{"127.0.0.1:" + port}))
+		})
+
+		g.It("Should let the proxy resolve host names with socks5h", func() {
+			proxy := newSocksServer("", "")
+			defer proxy.Close()
+			res, err := Request{Uri: "http://localhost:" + port, Proxy: "socks5h://" + proxy.Addr()}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+			gomega.Expect(proxy.Targets()).Should(gomega.Equal([]string{"localhost:" + port}))
+		})
+
+		g.It("Should authenticate with a username and password", func() {
+			proxy := newSocksServer("user", "secret")
+			defer proxy.Close()
+			res, err := Request{Uri: ts.URL, Proxy: "socks5://user:secret@" + proxy.Addr()}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+
+			_, err = Request{Uri: ts.URL, Proxy: "socks5://user:wrong@" + proxy.Addr()}.Do()
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			gomega.Expect(err.Error()).Should(gomega.ContainSubstring("authentication failed"))
+		})
+
+		g.It("Should report connection failures", func() {
+			proxy := newSocksServer("", "")
+			defer proxy.Close()
+			_, err := Request{Uri: "http://127.0.0.1:1", Proxy: "socks5://" + proxy.Addr()}.Do()
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			gomega.Expect(err.Error()).Should(gomega.ContainSubstring("connection refused"))
+			var goreqErr *Error
+			gomega.Expect(errors.As(err, &goreqErr)).Should(gomega.BeTrue())
+		})
+	})
+}
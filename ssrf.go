@@ -0,0 +1,160 @@
+package goreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+)
+
+// ErrBlockedAddress is returned when an SSRFGuard refuses a connection.
+var ErrBlockedAddress = errors.New("Connection to a blocked address")
+
+// DefaultDeniedNetworks are the networks an SSRFGuard without Deny refuses
+// connections to: loopback, private, link-local (including cloud metadata
+// services), shared, multicast and reserved addresses.
+var DefaultDeniedNetworks = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+// SSRFGuard protects against server side request forgery by refusing
+// connections to some networks. Addresses are checked when connecting,
+// after host names are resolved, so every redirect is checked and DNS
+// rebinding can not get around it. With a proxy, the proxy itself is
+// trusted and the host of each request is resolved and checked instead.
+// SOCKS5 proxies, socks5h included, are then given the checked address,
+// but HTTP proxies resolve the host again themselves and so are not safe
+// against DNS rebinding. Proxies from the environment are not used. A SSRFGuard must not be
+// changed once used.
+type SSRFGuard struct {
+	// Deny are the CIDR networks connections are refused to. It defaults
+	// to DefaultDeniedNetworks.
+	Deny []string
+	// Allow are exceptions to Deny.
+	Allow []string
+}
+
+type addressGuard struct {
+	deny, allow []*net.IPNet
+	dns         *DNS
+}
+
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks[i] = network
+	}
+	return networks, nil
+}
+
+func (g *SSRFGuard) compile(dns *DNS) (*addressGuard, error) {
+	deny := g.Deny
+	if deny == nil {
+		deny = DefaultDeniedNetworks
+	}
+	denied, err := parseNetworks(deny)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := parseNetworks(g.Allow)
+	if err != nil {
+		return nil, err
+	}
+	return &addressGuard{deny: denied, allow: allowed, dns: dns}, nil
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *addressGuard) check(ip net.IP) error {
+	if contains(g.deny, ip) && !contains(g.allow, ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+	}
+	return nil
+}
+
+// control checks the address a net.Dialer is about to connect to.
+func (g *addressGuard) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return g.check(ip)
+}
+
+// checkHost resolves host and checks all of its addresses.
+func (g *addressGuard) checkHost(ctx context.Context, host, port string) error {
+	var ips []net.IP
+	if g.dns != nil {
+		var err error
+		if ips, err = g.dns.Lookup(ctx, host, port); err != nil {
+			return err
+		}
+	} else if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	for _, ip := range ips {
+		if err := g.check(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// proxy wraps the Proxy function of a transport so the host of every
+// request sent through the proxy is checked.
+func (g *addressGuard) proxy(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		port := req.URL.Port()
+		if port == "" {
+			port = "80"
+			if req.URL.Scheme == "https" {
+				port = "443"
+			}
+		}
+		if err := g.checkHost(req.Context(), req.URL.Hostname(), port); err != nil {
+			return nil, err
+		}
+		return proxy(req)
+	}
+}
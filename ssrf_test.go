@@ -0,0 +1,112 @@
+package goreq
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestSSRFGuard(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("SSRFGuard", func() {
+		var ts, proxy *httptest.Server
+		var port string
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/redirect" {
+					http.Redirect(w, r, "http://127.0.0.2:"+port+"/", http.StatusFound)
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			_, port, _ = net.SplitHostPort(ts.Listener.Addr().String())
+			proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("proxied"))
+			}))
+		})
+
+		g.After(func() {
+			ts.Close()
+			proxy.Close()
+		})
+
+		g.It("Should refuse connections to internal networks", func() {
+			_, err := (&Client{SSRFGuard: &SSRFGuard{}}).Do(Request{Uri: ts.URL})
+			gomega.Expect(errors.Is(err, ErrBlockedAddress)).Should(gomega.BeTrue())
+		})
+
+		g.It("Should check resolved addresses", func() {
+			client := &Client{
+				SSRFGuard: &SSRFGuard{},
+				DNS:       &DNS{Hosts: map[string][]string{"rebind.test": {"127.0.0.1"}}},
+			}
+			_, err := client.Do(Request{Uri: "http://rebind.test:" + port})
+			gomega.Expect(errors.Is(err, ErrBlockedAddress)).Should(gomega.BeTrue())
+		})
+
+		g.It("Should allow exceptions", func() {
+			client := &Client{SSRFGuard: &SSRFGuard{Allow: []string{"127.0.0.1/32"}}}
+			res, err := client.Do(Request{Uri: ts.URL})
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+		})
+
+		g.It("Should check every redirect", func() {
+			client := &Client{SSRFGuard: &SSRFGuard{Allow: []string{"127.0.0.1/32"}}}
+			_, err := client.Do(Request{Uri: ts.URL + "/redirect", MaxRedirects: 1})
+			gomega.Expect(errors.Is(err, ErrBlockedAddress)).Should(gomega.BeTrue())
+		})
+
+		g.It("Should check the destination of proxied requests", func() {
+			client := &Client{
+				SSRFGuard: &SSRFGuard{},
+				DNS:       &DNS{Hosts: map[string][]string{"public.test": {"203.0.113.7"}}},
+			}
+			_, err := client.Do(Request{Uri: ts.URL, Proxy: proxy.URL})
+			gomega.Expect(errors.Is(err, ErrBlockedAddress)).Should(gomega.BeTrue())
+
+			res, err := client.Do(Request{Uri: "http://public.test", Proxy: proxy.URL})
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := res.Body.ToString()
+			gomega.Expect(str).Should(gomega.Equal("proxied"))
+		})
+
+		g.It("Should check the destination of SOCKS5 requests", func() {
+			socks := newSocksServer("", "")
+			defer socks.Close()
+			client := &Client{SSRFGuard: &SSRFGuard{}}
+			_, err := client.Do(Request{Uri: ts.URL, Proxy: "socks5h://" + socks.Addr()})
+			gomega.Expect(errors.Is(err, ErrBlockedAddress)).Should(gomega.BeTrue())
+			gomega.Expect(socks.Targets()).Should(gomega.BeEmpty())
+		})
+
+		g.It("Should send the checked address to SOCKS5 proxies", func() {
+			socks := newSocksServer("", "")
+			defer socks.Close()
+			u, _ := url.Parse(ts.URL)
+			client := &Client{
+				SSRFGuard: &SSRFGuard{Allow: []string{"127.0.0.1/32"}},
+				DNS:       &DNS{Hosts: map[string][]string{"public.test": {"127.0.0.1"}}},
+			}
+			res, err := client.Do(Request{Uri: "http://public.test:" + u.Port(), Proxy: "socks5h://" + socks.Addr()})
+			gomega.Expect(err).Should(gomega.BeNil())
+			res.Body.Close()
+			gomega.Expect(socks.Targets()).Should(gomega.Equal([]string{"127.0.0.1:" + u.Port()}))
+		})
+
+		g.It("Should report invalid networks", func() {
+			_, err := (&Client{SSRFGuard: &SSRFGuard{Deny: []string{"10.0.0.0"}}}).Do(Request{Uri: ts.URL})
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			gomega.Expect(errors.Is(err, ErrBlockedAddress)).Should(gomega.BeFalse())
+		})
+	})
+}
@@ -0,0 +1,184 @@
+package goreq
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TimeoutPhase is the part of an exchange a PhaseTimeouts limit applies to.
+type TimeoutPhase string
+
+const (
+	PhaseConnect      TimeoutPhase = "connect"
+	PhaseTLSHandshake TimeoutPhase = "TLS handshake"
+	PhaseFirstByte    TimeoutPhase = "first byte"
+	PhaseBodyIdle     TimeoutPhase = "body idle"
+)
+
+// PhaseTimeouts limits the phases of every exchange of a request, unlike
+// Request.Timeout which covers the whole request including reading the
+// body. When a limit is hit the request fails with an Error whose
+// Timeout() is true and whose Phase() is the phase that expired.
+type PhaseTimeouts struct {
+	// Connect limits getting a connection: resolving the host name,
+	// dialing and waiting for a free connection.
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	// FirstByte limits the wait for the response once the request is
+	// written.
+	FirstByte time.Duration
+	// BodyIdle limits how long a read of the response body waits for data.
+	// Time spent between reads is not counted, so slow consumers are fine.
+	BodyIdle time.Duration
+}
+
+// Phase returns the phase whose PhaseTimeouts limit expired, or an empty
+// string if the error is not such a timeout.
+func (e *Error) Phase() TimeoutPhase {
+	return e.phase
+}
+
+// newError wraps err in an Error, keeping the timeout and the phase of an
+// Error it may contain.
+func newError(err error) *Error {
+	e := &Error{timeout: isTimeout(err), Err: err}
+	var inner *Error
+	if errors.As(err, &inner) {
+		e.timeout = e.timeout || inner.timeout
+		e.phase = inner.phase
+	}
+	return e
+}
+
+// phaseTracker runs the timer of the phases in progress and cancels the
+// exchange when one expires.
+type phaseTracker struct {
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	timers  map[TimeoutPhase]*time.Timer
+	expired TimeoutPhase
+}
+
+func (t *phaseTracker) start(phase TimeoutPhase, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, running := t.timers[phase]; running {
+		return
+	}
+	t.timers[phase] = time.AfterFunc(d, func() { t.expire(phase) })
+}
+
+func (t *phaseTracker) stop(phase TimeoutPhase) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if timer, ok := t.timers[phase]; ok {
+		timer.Stop()
+		delete(t.timers, phase)
+	}
+}
+
+func (t *phaseTracker) expire(phase TimeoutPhase) {
+	t.mu.Lock()
+	if _, running := t.timers[phase]; !running {
+		// stopped while firing
+		t.mu.Unlock()
+		return
+	}
+	delete(t.timers, phase)
+	if t.expired == "" {
+		t.expired = phase
+	}
+	t.mu.Unlock()
+	t.cancel()
+}
+
+// err returns the error for the expired phase, if any.
+func (t *phaseTracker) err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.expired == "" {
+		return nil
+	}
+	return &Error{timeout: true, phase: t.expired, Err: fmt.Errorf("%s timeout", t.expired)}
+}
+
+func (p PhaseTimeouts) roundTrip(transport http.RoundTripper, req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	t := &phaseTracker{cancel: cancel, timers: make(map[TimeoutPhase]*time.Timer)}
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.start(PhaseConnect, p.Connect)
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.stop(PhaseConnect)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.start(PhaseTLSHandshake, p.TLSHandshake)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.stop(PhaseTLSHandshake)
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			t.stop(PhaseConnect)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.start(PhaseFirstByte, p.FirstByte)
+		},
+		GotFirstResponseByte: func() {
+			t.stop(PhaseFirstByte)
+		},
+	}
+	res, err := transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(ctx, trace)))
+	for _, phase := range []TimeoutPhase{PhaseConnect, PhaseTLSHandshake, PhaseFirstByte} {
+		t.stop(phase)
+	}
+	if err != nil {
+		cancel()
+		if phaseErr := t.err(); phaseErr != nil {
+			return nil, phaseErr
+		}
+		return nil, err
+	}
+	res.Body = &phaseBody{ReadCloser: res.Body, tracker: t, idle: p.BodyIdle}
+	return res, nil
+}
+
+// phaseBody enforces the BodyIdle limit and releases the context of the
+// exchange once closed.
+type phaseBody struct {
+	io.ReadCloser
+	tracker *phaseTracker
+	idle    time.Duration
+}
+
+func (b *phaseBody) Read(p []byte) (int, error) {
+	b.tracker.start(PhaseBodyIdle, b.idle)
+	n, err := b.ReadCloser.Read(p)
+	b.tracker.stop(PhaseBodyIdle)
+	if err != nil && err != io.EOF {
+		if phaseErr := b.tracker.err(); phaseErr != nil {
+			return n, phaseErr
+		}
+	}
+	return n, err
+}
+
+func (b *phaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.tracker.stop(PhaseBodyIdle)
+	b.tracker.cancel()
+	return err
+}
@@ -0,0 +1,126 @@
+package goreq
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestPhaseTimeouts(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Phase timeouts", func() {
+		var ts *httptest.Server
+		var silent net.Listener
+
+		g.Before(func() {
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/slow":
+					time.Sleep(200 * time.Millisecond)
+				case "/stall":
+					fmt.Fprint(w, "Hello")
+					w.(http.Flusher).Flush()
+					select {
+					case <-time.After(time.Second):
+					case <-r.Context().Done():
+					}
+					return
+				}
+				fmt.Fprint(w, "Hello world")
+			}))
+			// accepts connections but never answers
+			silent, _ = net.Listen("tcp", "127.0.0.1:0")
+			go func() {
+				for {
+					conn, err := silent.Accept()
+					if err != nil {
+						return
+					}
+					defer conn.Close()
+				}
+			}()
+		})
+
+		g.After(func() {
+			ts.Close()
+			silent.Close()
+		})
+
+		expectPhase := func(err error, phase TimeoutPhase) {
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+			goreqErr, ok := err.(*Error)
+			gomega.Expect(ok).Should(gomega.BeTrue())
+			gomega.Expect(goreqErr.Timeout()).Should(gomega.BeTrue())
+			gomega.Expect(goreqErr.Phase()).Should(gomega.Equal(phase))
+		}
+
+		g.It("Should time out connecting", func() {
+			hanging := &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+			}
+			client := &Client{DNS: &DNS{Resolver: hanging}, PhaseTimeouts: PhaseTimeouts{Connect: 50 * time.Millisecond}}
+			_, err := client.Do(Request{Uri: "http://hanging.test"})
+			expectPhase(err, PhaseConnect)
+		})
+
+		g.It("Should time out during the TLS handshake", func() {
+			_, err := Request{
+				Uri:           "https://" + silent.Addr().String(),
+				PhaseTimeouts: PhaseTimeouts{TLSHandshake: 50 * time.Millisecond},
+			}.Do()
+			expectPhase(err, PhaseTLSHandshake)
+		})
+
+		g.It("Should time out waiting for the first byte", func() {
+			_, err := Request{Uri: ts.URL + "/slow", PhaseTimeouts: PhaseTimeouts{FirstByte: 50 * time.Millisecond}}.Do()
+			expectPhase(err, PhaseFirstByte)
+
+			res, err := Request{Uri: ts.URL + "/slow", PhaseTimeouts: PhaseTimeouts{FirstByte: time.Second}}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+		})
+
+		g.It("Should time out when the body stalls", func() {
+			res, err := Request{Uri: ts.URL + "/stall", PhaseTimeouts: PhaseTimeouts{BodyIdle: 50 * time.Millisecond}}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			_, err = ioutil.ReadAll(res.Body)
+			expectPhase(err, PhaseBodyIdle)
+
+			_, err = Request{Uri: ts.URL + "/stall", BufferBody: true, PhaseTimeouts: PhaseTimeouts{BodyIdle: 50 * time.Millisecond}}.Do()
+			expectPhase(err, PhaseBodyIdle)
+		})
+
+		g.It("Should not count the time between body reads", func() {
+			res, err := (&Client{PhaseTimeouts: PhaseTimeouts{BodyIdle: 20 * time.Millisecond}}).Do(Request{Uri: ts.URL})
+			gomega.Expect(err).Should(gomega.BeNil())
+			buf := make([]byte, 5)
+			var read []byte
+			for {
+				time.Sleep(30 * time.Millisecond)
+				n, err := res.Body.Read(buf)
+				read = append(read, buf[:n]...)
+				if err == io.EOF {
+					break
+				}
+				gomega.Expect(err).Should(gomega.BeNil())
+			}
+			res.Body.Close()
+			gomega.Expect(string(read)).Should(gomega.Equal("Hello world"))
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package goreq
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// ErrPinMismatch is returned when no certificate presented by a server
+// matches the Pins of its TLSConfig.
+var ErrPinMismatch = errors.New("Server certificate does not match any pin")
+
+// TLSConfig holds the TLS settings of a Request or a Client. Requests using
+// a TLSConfig get a transport of their own. The requests of a Client using
+// the same *TLSConfig share it, so reuse the same value to keep connections
+// pooled. Requests sent without a Client open a new connection every time.
+//
+// A Client keeps the transport of every *TLSConfig it is given for as long
+// as it lives, so build a TLSConfig once and reuse it: a new one per
+// request makes the Client grow without bound.
+type TLSConfig struct {
+	// Certificates are the client certificates presented to servers asking
+	// for one. CertFile and KeyFile add a certificate loaded from PEM files.
+	Certificates []tls.Certificate
+	CertFile     string
+	KeyFile      string
+	// RootCAs are the certificate authorities used to verify servers.
+	// CAFile can be used instead to load them from a PEM bundle. The system
+	// roots are used if neither is set.
+	RootCAs *x509.CertPool
+	CAFile  string
+	// ServerName overrides the name sent with SNI and checked against the
+	// server certificate, which is the host of the URL otherwise.
+	ServerName string
+	// MinVersion is the minimum TLS version accepted, like tls.VersionTLS12.
+	MinVersion   uint16
+	CipherSuites []uint16
+	// InsecureSkipVerify disables the verification of server certificates.
+	InsecureSkipVerify bool
+	// Pins, if set, are SHA-256 hashes of subject public key infos, base64
+	// encoded and optionally prefixed with "sha256/" (see SPKIPin). The
+	// connection is refused unless a certificate of the verified chain, or
+	// the server certificate itself when verification is skipped, matches
+	// one of them. List backup pins along with the current ones so keys can be
+	// rotated.
+	Pins []string
+}
+
+// SPKIPin returns the pin of cert, in the form used by TLSConfig.Pins.
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Build returns the crypto/tls configuration described by c.
+func (c *TLSConfig) Build() (*tls.Config, error) {
+	config := &tls.Config{
+		Certificates:       append([]tls.Certificate(nil), c.Certificates...),
+		RootCAs:            c.RootCAs,
+		ServerName:         c.ServerName,
+		MinVersion:         c.MinVersion,
+		CipherSuites:       c.CipherSuites,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+	if c.CAFile != "" {
+		if c.RootCAs != nil {
+			return nil, errors.New("TLSConfig can not have both RootCAs and CAFile")
+		}
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate found in %s", c.CAFile)
+		}
+	}
+	if len(c.Pins) > 0 {
+		pins := make(map[string]bool, len(c.Pins))
+		for _, pin := range c.Pins {
+			if !strings.HasPrefix(pin, "sha256/") {
+				pin = "sha256/" + pin
+			}
+			pins[pin] = true
+		}
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			// check the verified chains when there are some so a pinned key
+			// can not be presented along with an unrelated chain. Without
+			// verification only the leaf key is proven by the handshake, so
+			// it is the only one that can match
+			chains := state.VerifiedChains
+			if len(chains) == 0 && len(state.PeerCertificates) > 0 {
+				chains = [][]*x509.Certificate{state.PeerCertificates[:1]}
+			}
+			for _, chain := range chains {
+				for _, cert := range chain {
+					if pins[SPKIPin(cert)] {
+						return nil
+					}
+				}
+			}
+			return ErrPinMismatch
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,240 @@
+package goreq
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+// selfSigned returns a certificate for commonName signed by itself, along
+// with its PEM encoded certificate and key.
+func selfSigned(commonName string) (tls.Certificate, []byte, []byte) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	cert, _ := tls.X509KeyPair(certPEM, keyPEM)
+	cert.Leaf, _ = x509.ParseCertificate(der)
+	return cert, certPEM, keyPEM
+}
+
+// connectProxy returns an HTTP proxy that only supports CONNECT tunnels and
+// counts them.
+func connectProxy(tunnels *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" {
+			w.WriteHeader(405)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(502)
+			return
+		}
+		atomic.AddInt32(tunnels, 1)
+		w.WriteHeader(200)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+}
+
+func TestTLSConfig(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("TLSConfig", func() {
+		var ts *httptest.Server
+		var roots *x509.CertPool
+		var clientCert tls.Certificate
+		var dir string
+
+		g.Before(func() {
+			var certPEM, keyPEM []byte
+			clientCert, certPEM, keyPEM = selfSigned("client")
+			clientCAs := x509.NewCertPool()
+			clientCAs.AppendCertsFromPEM(certPEM)
+
+			ts = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+			}))
+			ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs, MaxVersion: tls.VersionTLS12}
+			ts.StartTLS()
+			roots = x509.NewCertPool()
+			roots.AddCert(ts.Certificate())
+
+			dir, _ = ioutil.TempDir("", "goreq-tls")
+			ioutil.WriteFile(filepath.Join(dir, "client.pem"), certPEM, 0600)
+			ioutil.WriteFile(filepath.Join(dir, "client-key.pem"), keyPEM, 0600)
+			ioutil.WriteFile(filepath.Join(dir, "ca.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600)
+		})
+
+		g.After(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+
+		g.It("Should present client certificates and verify with custom roots", func() {
+			res, err := Request{Uri: ts.URL, TLS: &TLSConfig{Certificates: []tls.Certificate{clientCert}, RootCAs: roots}}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := res.Body.ToString()
+			gomega.Expect(str).Should(gomega.Equal("client"))
+		})
+
+		g.It("Should load certificates from PEM files", func() {
+			client := &Client{TLS: &TLSConfig{
+				CertFile: filepath.Join(dir, "client.pem"),
+				KeyFile:  filepath.Join(dir, "client-key.pem"),
+				CAFile:   filepath.Join(dir, "ca.pem"),
+			}}
+			res, err := client.Do(Request{Uri: ts.URL})
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+		})
+
+		g.It("Should verify the server with the ServerName override", func() {
+			config := &TLSConfig{Certificates: []tls.Certificate{clientCert}, RootCAs: roots, ServerName: "example.com"}
+			_, err := Request{Uri: ts.URL, TLS: config}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+
+			config = &TLSConfig{Certificates: []tls.Certificate{clientCert}, RootCAs: roots, ServerName: "other.test"}
+			_, err = Request{Uri: ts.URL, TLS: config}.Do()
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+		})
+
+		g.It("Should enforce the minimum version", func() {
+			config := &TLSConfig{Certificates: []tls.Certificate{clientCert}, RootCAs: roots, MinVersion: tls.VersionTLS13}
+			_, err := Request{Uri: ts.URL, TLS: config}.Do()
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+		})
+
+		g.It("Should fail without the server CA", func() {
+			_, err := Request{Uri: ts.URL, TLS: &TLSConfig{Certificates: []tls.Certificate{clientCert}}}.Do()
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+		})
+
+		g.It("Should not change the DefaultTransport", func() {
+			insecure := func() bool {
+				config := DefaultTransport.(*http.Transport).TLSClientConfig
+				return config != nil && config.InsecureSkipVerify
+			}
+			before := insecure()
+			res, err := Request{Uri: ts.URL, Insecure: true, TLS: &TLSConfig{Certificates: []tls.Certificate{clientCert}}}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+			gomega.Expect(insecure()).Should(gomega.Equal(before))
+		})
+
+		g.It("Should not keep connections of requests sent without a Client", func() {
+			var open int32
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			}))
+			ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				switch state {
+				case http.StateNew:
+					atomic.AddInt32(&open, 1)
+				case http.StateClosed, http.StateHijacked:
+					atomic.AddInt32(&open, -1)
+				}
+			}
+			ts.StartTLS()
+			defer ts.Close()
+
+			for i := 0; i < 3; i++ {
+				res, err := Request{Uri: ts.URL, TLS: &TLSConfig{InsecureSkipVerify: true}}.Do()
+				gomega.Expect(err).Should(gomega.BeNil())
+				res.Body.ToString()
+			}
+			gomega.Eventually(func() int32 { return atomic.LoadInt32(&open) }).Should(gomega.Equal(int32(0)))
+		})
+
+		g.It("Should accept servers matching a pin", func() {
+			pins := []string{"sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", SPKIPin(ts.Certificate())}
+			res, err := Request{Uri: ts.URL, TLS: &TLSConfig{Certificates: []tls.Certificate{clientCert}, RootCAs: roots, Pins: pins}}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+		})
+
+		g.It("Should refuse servers matching no pin", func() {
+			other, _, _ := selfSigned("other")
+			pins := []string{SPKIPin(other.Leaf)}
+			_, err := Request{Uri: ts.URL, TLS: &TLSConfig{Certificates: []tls.Certificate{clientCert}, RootCAs: roots, Pins: pins}}.Do()
+			gomega.Expect(errors.Is(err, ErrPinMismatch)).Should(gomega.BeTrue())
+		})
+
+		g.It("Should check pins through a proxy", func() {
+			var tunnels int32
+			proxy := connectProxy(&tunnels)
+			defer proxy.Close()
+
+			other, _, _ := selfSigned("other")
+			config := &TLSConfig{Certificates: []tls.Certificate{clientCert}, RootCAs: roots, Pins: []string{SPKIPin(other.Leaf)}}
+			_, err := Request{Uri: ts.URL, Proxy: proxy.URL, TLS: config}.Do()
+			gomega.Expect(errors.Is(err, ErrPinMismatch)).Should(gomega.BeTrue())
+
+			config = &TLSConfig{Certificates: []tls.Certificate{clientCert}, RootCAs: roots, Pins: []string{SPKIPin(ts.Certificate())}}
+			res, err := Request{Uri: ts.URL, Proxy: proxy.URL, TLS: config}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+			gomega.Expect(atomic.LoadInt32(&tunnels)).Should(gomega.Equal(int32(2)))
+		})
+
+		g.It("Should only match the leaf against pins when not verifying", func() {
+			leaf, _, _ := selfSigned("attacker")
+			ca, _, _ := selfSigned("pinned ca")
+			// the pinned CA is sent along without having signed the leaf
+			leaf.Certificate = append(leaf.Certificate, ca.Leaf.Raw)
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			ts.TLS = &tls.Config{Certificates: []tls.Certificate{leaf}}
+			ts.StartTLS()
+			defer ts.Close()
+
+			_, err := Request{Uri: ts.URL, Insecure: true, TLS: &TLSConfig{Pins: []string{SPKIPin(ca.Leaf)}}}.Do()
+			gomega.Expect(errors.Is(err, ErrPinMismatch)).Should(gomega.BeTrue())
+
+			res, err := Request{Uri: ts.URL, Insecure: true, TLS: &TLSConfig{Pins: []string{SPKIPin(leaf.Leaf)}}}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+		})
+
+		g.It("Should report invalid settings", func() {
+			_, err := Request{Uri: ts.URL, TLS: &TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}}.Do()
+			gomega.Expect(err).ShouldNot(gomega.BeNil())
+		})
+	})
+}
@@ -0,0 +1,216 @@
+package goreq
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// transportKey identifies the connection level settings of a request.
+// Requests of a Client with the same settings share a transport, and so
+// its pool of connections.
+type transportKey struct {
+	tls         *TLSConfig
+	insecure    bool
+	proxy       string
+	proxyHeader string
+	socket      string
+	dns         *DNS
+	guard       *SSRFGuard
+	pool        PoolConfig
+	protocol    Protocol
+	// base is the DefaultTransport cloned by requests that only skip
+	// verification.
+	base *http.Transport
+}
+
+// transport returns the transport for the connection level settings of r,
+// or nil if r has none and the package DefaultTransport is used.
+func (r Request) transport() (http.RoundTripper, error) {
+	key := transportKey{
+		tls:         r.tlsConfig(),
+		insecure:    r.Insecure,
+		proxy:       r.Proxy,
+		proxyHeader: fmt.Sprint(r.proxyConnectHeaders),
+		pool:        r.pool(),
+		protocol:    r.protocol(),
+		socket:      r.socketPath(),
+		dns:         r.dns(),
+		guard:       r.ssrfGuard(),
+	}
+	if !key.custom() {
+		return nil, nil
+	}
+	if key.insecureOnly() {
+		base, ok := DefaultTransport.(*http.Transport)
+		if !ok {
+			// a DefaultTransport that is not an *http.Transport can not be
+			// told to skip verification, it is used as is
+			return nil, nil
+		}
+		key.base = base
+	}
+	if r.client == nil {
+		// nothing would ever release a shared transport, so requests
+		// without a Client get one for themselves that keeps no connection
+		// open once done
+		t, err := r.newTransport(key, nil)
+		if err != nil {
+			return nil, err
+		}
+		t.DisableKeepAlives = true
+		return r.trackProxy(configureProtocol(t, key.protocol)), nil
+	}
+	t, ok := r.client.transports.Load(key)
+	if !ok {
+		created, err := r.newTransport(key, &r.client.counters)
+		if err != nil {
+			return nil, err
+		}
+		t, _ = r.client.transports.LoadOrStore(key, configureProtocol(created, key.protocol))
+	}
+	return r.trackProxy(r.client.counters.countRequests(t.(http.RoundTripper))), nil
+}
+
+// custom reports whether the settings need a transport other than the
+// package DefaultTransport.
+func (k transportKey) custom() bool {
+	return k.tls != nil || k.insecure || k.proxy != "" || k.socket != "" || k.dns != nil || k.guard != nil || k.pool != (PoolConfig{}) || k.protocol != DefaultProtocol
+}
+
+// insecureOnly reports whether skipping verification is the only custom
+// setting, in which case the DefaultTransport is cloned rather than
+// replaced.
+func (k transportKey) insecureOnly() bool {
+	return k == transportKey{insecure: true, proxyHeader: k.proxyHeader, protocol: DefaultProtocol}
+}
+
+// redactProxy hides the password of the proxy URL proxy.
+func redactProxy(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return proxy
+	}
+	return u.Redacted()
+}
+
+// newDialer returns a dial function based on DefaultDialer, which is read
+// on every dial so SetConnectTimeout keeps working.
+func newDialer(key transportKey, guard *addressGuard, counters *poolCounters) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialer := *DefaultDialer
+		if key.pool.KeepAlive != 0 {
+			dialer.KeepAlive = key.pool.KeepAlive
+		}
+		if guard != nil {
+			dialer.Control = guard.control
+		}
+		return counters.countConn(dialer.DialContext(ctx, network, addr))
+	}
+	if key.dns != nil {
+		return key.dns.dialer(dial)
+	}
+	return dial
+}
+
+func (r Request) newTransport(key transportKey, counters *poolCounters) (*http.Transport, error) {
+	if key.base != nil {
+		return insecureTransport(key.base, counters), nil
+	}
+	var guard *addressGuard
+	if key.guard != nil {
+		var err error
+		if guard, err = key.guard.compile(key.dns); err != nil {
+			return nil, err
+		}
+	}
+	dial := newDialer(key, guard, counters)
+	// proxies are dialed directly, they are trusted even with an SSRFGuard
+	direct := dial
+	if guard != nil {
+		direct = newDialer(key, nil, counters)
+	}
+	t := &http.Transport{
+		DialContext:           dial,
+		Proxy:                 http.ProxyFromEnvironment,
+		MaxIdleConns:          key.pool.MaxIdleConns,
+		MaxIdleConnsPerHost:   key.pool.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       key.pool.MaxConnsPerHost,
+		IdleConnTimeout:       key.pool.idleConnTimeout(),
+		TLSHandshakeTimeout:   key.pool.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: key.pool.ResponseHeaderTimeout,
+		ForceAttemptHTTP2:     key.pool.HTTP2,
+	}
+	if guard != nil {
+		t.Proxy = nil
+	}
+	if key.socket != "" {
+		t.Proxy = nil
+		socketDial := unixDialer(key.socket)
+		t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return counters.countConn(socketDial(ctx, network, addr))
+		}
+		return t, r.configureTLS(t, key)
+	}
+	if key.proxy != "" {
+		proxyUrl, err := url.Parse(key.proxy)
+		if err != nil {
+			return nil, err
+		}
+		if isSocks(key.proxy) {
+			socks := newSocksDialer(proxyUrl, direct, key.dns)
+			socks.guard = guard
+			t.Proxy = nil
+			t.DialContext = socks.DialContext
+			return t, r.configureTLS(t, key)
+		}
+		t.DialContext = direct
+		t.Proxy = http.ProxyURL(proxyUrl)
+		if guard != nil {
+			t.Proxy = guard.proxy(t.Proxy)
+		}
+		t.ProxyConnectHeader = make(http.Header)
+		for _, header := range r.proxyConnectHeaders {
+			t.ProxyConnectHeader[header.name] = []string{header.value}
+		}
+	}
+	return t, r.configureTLS(t, key)
+}
+
+// insecureTransport returns a copy of base that skips verification.
+func insecureTransport(base *http.Transport, counters *poolCounters) *http.Transport {
+	t := base.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.InsecureSkipVerify = true
+	if dial := t.DialContext; dial != nil {
+		t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return counters.countConn(dial(ctx, network, addr))
+		}
+	} else if dial := t.Dial; dial != nil {
+		t.Dial = func(network, addr string) (net.Conn, error) {
+			return counters.countConn(dial(network, addr))
+		}
+	}
+	return t
+}
+
+func (r Request) configureTLS(t *http.Transport, key transportKey) error {
+	if key.tls != nil {
+		config, err := key.tls.Build()
+		if err != nil {
+			return err
+		}
+		if key.insecure {
+			config.InsecureSkipVerify = true
+		}
+		t.TLSClientConfig = config
+	} else if key.insecure {
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return nil
+}
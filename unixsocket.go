@@ -0,0 +1,45 @@
+package goreq
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// unixHost is the host of the requests sent through a Unix socket given in
+// the URI.
+const unixHost = "localhost"
+
+// splitUnixURI splits a URI like unix:///var/run/docker.sock:/v1.41/info
+// into the path of the socket and the URI of the HTTP request sent through
+// it.
+func splitUnixURI(uri string) (socket, httpURI string, ok bool) {
+	if !strings.HasPrefix(uri, "unix://") {
+		return "", "", false
+	}
+	rest := strings.TrimPrefix(uri, "unix://")
+	i := strings.Index(rest, ":")
+	if i < 0 {
+		return rest, "http://" + unixHost + "/", true
+	}
+	return rest[:i], "http://" + unixHost + rest[i+1:], true
+}
+
+// socketPath returns the Unix socket requests are sent through, from the
+// Uri of r or the SocketPath of its client.
+func (r Request) socketPath() string {
+	if socket, _, ok := splitUnixURI(r.Uri); ok {
+		return socket
+	}
+	if r.client != nil {
+		return r.client.SocketPath
+	}
+	return ""
+}
+
+// unixDialer dials socket whatever the address asked for.
+func unixDialer(socket string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return DefaultDialer.DialContext(ctx, "unix", socket)
+	}
+}
@@ -0,0 +1,83 @@
+package goreq
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/onsi/gomega"
+)
+
+func TestUnixSocket(t *testing.T) {
+	g := goblin.Goblin(t)
+	gomega.RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Unix sockets", func() {
+		var ts *httptest.Server
+		var dir, socket string
+
+		g.Before(func() {
+			dir, _ = ioutil.TempDir("", "goreq-unix")
+			socket = filepath.Join(dir, "api.sock")
+			l, _ := net.Listen("unix", socket)
+			ts = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1.41/info":
+					w.Header().Set("Content-Type", "application/json")
+					fmt.Fprintf(w, `{"path": %q, "all": %q}`, r.URL.Path, r.URL.Query().Get("all"))
+				case "/redirect":
+					http.Redirect(w, r, "/v1.41/info", http.StatusFound)
+				case "/gzip":
+					w.Header().Set("Content-Encoding", "gzip")
+					gz := gzip.NewWriter(w)
+					gz.Write([]byte("compressed"))
+					gz.Close()
+				}
+			}))
+			ts.Listener = l
+			ts.Start()
+		})
+
+		g.After(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+
+		g.It("Should send requests to the socket of unix URIs", func() {
+			res, err := Request{Uri: "unix://" + socket + ":/v1.41/info", QueryString: struct{ All string }{"1"}}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			var info struct{ Path, All string }
+			gomega.Expect(res.Body.FromJsonTo(&info)).Should(gomega.Succeed())
+			gomega.Expect(info.Path).Should(gomega.Equal("/v1.41/info"))
+			gomega.Expect(info.All).Should(gomega.Equal("1"))
+		})
+
+		g.It("Should build normal requests from unix URIs", func() {
+			req, err := Request{Uri: "unix://" + socket + ":/v1.41/info"}.NewRequest()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(req.URL.String()).Should(gomega.Equal("http://localhost/v1.41/info"))
+		})
+
+		g.It("Should follow redirects through the socket", func() {
+			res, err := Request{Uri: "unix://" + socket + ":/redirect", MaxRedirects: 1}.Do()
+			gomega.Expect(err).Should(gomega.BeNil())
+			gomega.Expect(res.StatusCode).Should(gomega.Equal(200))
+			gomega.Expect(res.Uri).Should(gomega.Equal("http://localhost/v1.41/info"))
+		})
+
+		g.It("Should use the SocketPath of the client", func() {
+			client := &Client{SocketPath: socket}
+			res, err := client.Do(Request{Uri: "http://docker/gzip", Compression: Gzip()})
+			gomega.Expect(err).Should(gomega.BeNil())
+			str, _ := res.Body.ToString()
+			gomega.Expect(str).Should(gomega.Equal("compressed"))
+		})
+	})
+}